@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/chromedp/cdproto/cdp"
+)
+
+// screenshot [path] [--full] [--selector <sel>] [--format png|jpeg] [--quality <n>]；不指定 path 时以 base64 返回
+func cmdScreenshot(ctx context.Context, s *session, args []string) (any, error) {
+	const usage = "screenshot [path] [--full] [--selector <sel>] [--format png|jpeg] [--quality <n>]"
+	var opts screenshotOptions
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--full":
+			opts.FullPage = true
+		case "--selector", "--format", "--quality":
+			if i+1 >= len(args) {
+				return nil, usageError(usage)
+			}
+			i++
+			switch args[i-1] {
+			case "--selector":
+				opts.Selector = args[i]
+			case "--format":
+				opts.Format = args[i]
+			default:
+				n, err := strconv.Atoi(args[i])
+				if err != nil {
+					return nil, usageError("screenshot --quality <n>，quality 须为 1 到 100 的整数")
+				}
+				opts.Quality = n
+			}
+		default:
+			if opts.Path != "" {
+				return nil, usageError(usage)
+			}
+			opts.Path = args[i]
+		}
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	var node cdp.NodeID
+	if opts.Selector != "" {
+		id, err := s.resolveNode(ctx, opts.Selector)
+		if err != nil {
+			return nil, err
+		}
+		node = id
+	}
+	data, err := captureScreenshot(ctx, opts, node)
+	if err != nil {
+		return nil, err
+	}
+	return saveCapture(data, "image/"+opts.Format, opts.Path)
+}
+
+// pdf [path] [--paper <name>] [--landscape] [--margin <css>] [--background] [--scale <n>] [--pages <ranges>]；
+// 只有无头模式的会话支持，不指定 path 时以 base64 返回
+func cmdPDF(ctx context.Context, s *session, args []string) (any, error) {
+	const usage = "pdf [path] [--paper <name>] [--landscape] [--margin <css>] [--background] [--scale <n>] [--pages <ranges>]"
+	var opts pdfOptions
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--landscape":
+			opts.Landscape = true
+		case "--background":
+			opts.PrintBackground = true
+		case "--paper", "--margin", "--scale", "--pages":
+			if i+1 >= len(args) {
+				return nil, usageError(usage)
+			}
+			i++
+			switch args[i-1] {
+			case "--paper":
+				opts.Paper = args[i]
+			case "--margin":
+				opts.Margin = args[i]
+			case "--pages":
+				opts.PageRanges = args[i]
+			default:
+				n, err := strconv.ParseFloat(args[i], 64)
+				if err != nil {
+					return nil, usageError(fmt.Sprintf("pdf --scale <n>，scale 不是数字: %s", args[i]))
+				}
+				opts.Scale = n
+			}
+		default:
+			if opts.Path != "" {
+				return nil, usageError(usage)
+			}
+			opts.Path = args[i]
+		}
+	}
+	params, err := opts.params()
+	if err != nil {
+		return nil, err
+	}
+	data, err := capturePDF(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return saveCapture(data, "application/pdf", opts.Path)
+}
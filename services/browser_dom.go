@@ -0,0 +1,675 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/dom"
+	"github.com/chromedp/cdproto/input"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+	"github.com/chromedp/chromedp/kb"
+)
+
+// 将选择器转换为 chromedp 查询：@eN 按快照引用解析，xpath= 前缀或 // 开头按 XPath 处理，其余按 CSS 处理
+func (s *session) queryOptions(ctx context.Context, sel string) (any, []chromedp.QueryOption, error) {
+	if ref, ok := parseRef(sel); ok {
+		id, err := s.resolveRef(ctx, ref)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []cdp.NodeID{id}, []chromedp.QueryOption{chromedp.ByNodeID}, nil
+	}
+
+	query, opts := selectorQuery(sel)
+	if s.frame != nil {
+		opts = append(opts, chromedp.FromNode(s.frame))
+	}
+	return query, opts, nil
+}
+
+// 将 CSS 或 XPath 选择器转换为 chromedp 查询，不支持 @eN 引用
+func selectorQuery(sel string) (string, []chromedp.QueryOption) {
+	switch {
+	case strings.HasPrefix(sel, "xpath="):
+		return strings.TrimPrefix(sel, "xpath="), []chromedp.QueryOption{chromedp.BySearch}
+	case strings.HasPrefix(sel, "//"), strings.HasPrefix(sel, "(//"):
+		return sel, []chromedp.QueryOption{chromedp.BySearch}
+	}
+	return strings.TrimPrefix(sel, "css="), []chromedp.QueryOption{chromedp.ByQueryAll}
+}
+
+// 将选择器解析为节点 ID；wait 为 true 时等待元素出现直至超时，否则立即返回（可能为空）
+func (s *session) resolveNodes(ctx context.Context, sel string, wait bool) ([]cdp.NodeID, error) {
+	query, opts, err := s.queryOptions(ctx, sel)
+	if err != nil {
+		return nil, err
+	}
+	if !wait {
+		opts = append(opts, chromedp.AtLeast(0))
+	}
+	var ids []cdp.NodeID
+	if err := chromedp.Run(ctx, chromedp.NodeIDs(query, &ids, opts...)); err != nil {
+		return nil, wrapError(err, codeSelectorTimeout, fmt.Sprintf("查找元素 %s 失败", sel))
+	}
+	return ids, nil
+}
+
+// 解析选择器并返回第一个匹配的节点
+func (s *session) resolveNode(ctx context.Context, sel string) (cdp.NodeID, error) {
+	ids, err := s.resolveNodes(ctx, sel, true)
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, newError(codeNotFound, fmt.Sprintf("元素不存在: %s", sel), nil)
+	}
+	return ids[0], nil
+}
+
+// 以节点为 this 调用 JS 函数，返回值解码到 res（可为 nil）
+func callOnNode(ctx context.Context, id cdp.NodeID, fn string, res any, args ...any) error {
+	return chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		obj, err := dom.ResolveNode().WithNodeID(id).Do(ctx)
+		if err != nil {
+			return err
+		}
+		defer runtime.ReleaseObject(obj.ObjectID).Do(ctx)
+		return callOnObject(ctx, obj.ObjectID, fn, res, args...)
+	}))
+}
+
+// 以远程对象为 this 调用 JS 函数，须在 chromedp.ActionFunc 中调用
+func callOnObject(ctx context.Context, id runtime.RemoteObjectID, fn string, res any, args ...any) error {
+	return chromedp.CallFunctionOn(fn, res, func(p *runtime.CallFunctionOnParams) *runtime.CallFunctionOnParams {
+		return p.WithObjectID(id).WithAwaitPromise(true)
+	}, args...).Do(ctx)
+}
+
+// 将节点滚动到可见区域并返回其中心点坐标
+func nodeCenter(ctx context.Context, id cdp.NodeID) (float64, float64, error) {
+	var x, y float64
+	err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		if err := dom.ScrollIntoViewIfNeeded().WithNodeID(id).Do(ctx); err != nil {
+			return err
+		}
+		quads, err := dom.GetContentQuads().WithNodeID(id).Do(ctx)
+		if err != nil {
+			return err
+		}
+		if len(quads) == 0 || len(quads[0]) < 8 {
+			return newError(codeNotFound, "元素没有可见区域", nil)
+		}
+		q := quads[0]
+		x = (q[0] + q[2] + q[4] + q[6]) / 4
+		y = (q[1] + q[3] + q[5] + q[7]) / 4
+		return nil
+	}))
+	return x, y, err
+}
+
+// click <sel>
+func cmdClick(ctx context.Context, s *session, args []string) (any, error) {
+	if len(args) < 1 {
+		return nil, usageError("click <sel>")
+	}
+	id, err := s.resolveNode(ctx, args[0])
+	if err != nil {
+		return nil, err
+	}
+	err = chromedp.Run(ctx, chromedp.Click([]cdp.NodeID{id}, chromedp.ByNodeID))
+	return nil, wrapError(err, codeSelectorTimeout, "点击失败")
+}
+
+// dblclick <sel>
+func cmdDoubleClick(ctx context.Context, s *session, args []string) (any, error) {
+	if len(args) < 1 {
+		return nil, usageError("dblclick <sel>")
+	}
+	id, err := s.resolveNode(ctx, args[0])
+	if err != nil {
+		return nil, err
+	}
+	err = chromedp.Run(ctx, chromedp.DoubleClick([]cdp.NodeID{id}, chromedp.ByNodeID))
+	return nil, wrapError(err, codeSelectorTimeout, "双击失败")
+}
+
+// fill <sel> <text>：清空后输入
+func cmdFill(ctx context.Context, s *session, args []string) (any, error) {
+	if len(args) < 2 {
+		return nil, usageError("fill <sel> <text>")
+	}
+	id, err := s.resolveNode(ctx, args[0])
+	if err != nil {
+		return nil, err
+	}
+	err = callOnNode(ctx, id, `function() {
+		this.focus();
+		if (this.isContentEditable) {
+			this.textContent = '';
+		} else {
+			this.value = '';
+		}
+		this.dispatchEvent(new Event('input', {bubbles: true}));
+	}`, nil)
+	if err != nil {
+		return nil, wrapError(err, codeSelectorTimeout, "清空输入框失败")
+	}
+	err = chromedp.Run(ctx, chromedp.SendKeys([]cdp.NodeID{id}, strings.Join(args[1:], " "), chromedp.ByNodeID))
+	return nil, wrapError(err, codeSelectorTimeout, "输入失败")
+}
+
+// type <sel> <text>：在现有内容后追加输入
+func cmdType(ctx context.Context, s *session, args []string) (any, error) {
+	if len(args) < 2 {
+		return nil, usageError("type <sel> <text>")
+	}
+	id, err := s.resolveNode(ctx, args[0])
+	if err != nil {
+		return nil, err
+	}
+	err = chromedp.Run(ctx, chromedp.SendKeys([]cdp.NodeID{id}, strings.Join(args[1:], " "), chromedp.ByNodeID))
+	return nil, wrapError(err, codeSelectorTimeout, "输入失败")
+}
+
+// 按键名称到 chromedp 键值的映射
+var keyNames = map[string]string{
+	"enter":      kb.Enter,
+	"tab":        kb.Tab,
+	"escape":     kb.Escape,
+	"esc":        kb.Escape,
+	"backspace":  kb.Backspace,
+	"delete":     kb.Delete,
+	"space":      " ",
+	"arrowup":    kb.ArrowUp,
+	"arrowdown":  kb.ArrowDown,
+	"arrowleft":  kb.ArrowLeft,
+	"arrowright": kb.ArrowRight,
+	"home":       kb.Home,
+	"end":        kb.End,
+	"pageup":     kb.PageUp,
+	"pagedown":   kb.PageDown,
+}
+
+var modifierNames = map[string]input.Modifier{
+	"alt":     input.ModifierAlt,
+	"control": input.ModifierCtrl,
+	"ctrl":    input.ModifierCtrl,
+	"meta":    input.ModifierMeta,
+	"cmd":     input.ModifierCommand,
+	"shift":   input.ModifierShift,
+}
+
+// press <key>，支持 Control+a 形式的组合键
+func cmdPress(ctx context.Context, s *session, args []string) (any, error) {
+	if len(args) < 1 {
+		return nil, usageError("press <key>")
+	}
+	parts := strings.Split(args[0], "+")
+	var modifiers []input.Modifier
+	for _, part := range parts[:len(parts)-1] {
+		m, ok := modifierNames[strings.ToLower(part)]
+		if !ok {
+			return nil, usageError(fmt.Sprintf("press <key>，未知的修饰键: %s", part))
+		}
+		modifiers = append(modifiers, m)
+	}
+	key := parts[len(parts)-1]
+	if v, ok := keyNames[strings.ToLower(key)]; ok {
+		key = v
+	}
+
+	var opts []chromedp.KeyOption
+	if len(modifiers) > 0 {
+		opts = append(opts, chromedp.KeyModifiers(modifiers...))
+	}
+	err := chromedp.Run(ctx, chromedp.KeyEvent(key, opts...))
+	return nil, wrapError(err, codeSelectorTimeout, "按键失败")
+}
+
+// hover <sel>
+func cmdHover(ctx context.Context, s *session, args []string) (any, error) {
+	if len(args) < 1 {
+		return nil, usageError("hover <sel>")
+	}
+	id, err := s.resolveNode(ctx, args[0])
+	if err != nil {
+		return nil, err
+	}
+	x, y, err := nodeCenter(ctx, id)
+	if err != nil {
+		return nil, wrapError(err, codeSelectorTimeout, "定位元素失败")
+	}
+	err = chromedp.Run(ctx, chromedp.MouseEvent(input.MouseMoved, x, y))
+	return nil, wrapError(err, codeSelectorTimeout, "悬停失败")
+}
+
+// select <sel> <value...>：按 value 或显示文本选择选项
+func cmdSelect(ctx context.Context, s *session, args []string) (any, error) {
+	if len(args) < 2 {
+		return nil, usageError("select <sel> <value...>")
+	}
+	id, err := s.resolveNode(ctx, args[0])
+	if err != nil {
+		return nil, err
+	}
+	var selected []string
+	err = callOnNode(ctx, id, `function(values) {
+		if (!this.options) {
+			throw new Error('element is not a <select>');
+		}
+		const selected = [];
+		for (const option of this.options) {
+			option.selected = values.includes(option.value) || values.includes(option.label.trim());
+			if (option.selected) {
+				selected.push(option.value);
+			}
+		}
+		this.dispatchEvent(new Event('input', {bubbles: true}));
+		this.dispatchEvent(new Event('change', {bubbles: true}));
+		return selected;
+	}`, &selected, args[1:])
+	if err != nil {
+		return nil, wrapError(err, codeSelectorTimeout, "选择选项失败")
+	}
+	if len(selected) == 0 {
+		return nil, newError(codeNotFound, fmt.Sprintf("没有匹配的选项: %s", strings.Join(args[1:], ", ")), nil)
+	}
+	return selected, nil
+}
+
+// check <sel> / uncheck <sel>
+func checkCommand(want bool) func(context.Context, *session, []string) (any, error) {
+	return func(ctx context.Context, s *session, args []string) (any, error) {
+		if len(args) < 1 {
+			return nil, usageError("check|uncheck <sel>")
+		}
+		id, err := s.resolveNode(ctx, args[0])
+		if err != nil {
+			return nil, err
+		}
+		var checked bool
+		err = callOnNode(ctx, id, `function(want) {
+			if (!!this.checked !== want) {
+				this.click();
+			}
+			return !!this.checked;
+		}`, &checked, want)
+		if err != nil {
+			return nil, wrapError(err, codeSelectorTimeout, "切换选中状态失败")
+		}
+		return map[string]bool{"checked": checked}, nil
+	}
+}
+
+// upload <sel> <file...>
+func cmdUpload(ctx context.Context, s *session, args []string) (any, error) {
+	if len(args) < 2 {
+		return nil, usageError("upload <sel> <file...>")
+	}
+	id, err := s.resolveNode(ctx, args[0])
+	if err != nil {
+		return nil, err
+	}
+	err = chromedp.Run(ctx, chromedp.SetUploadFiles([]cdp.NodeID{id}, args[1:], chromedp.ByNodeID))
+	return nil, wrapError(err, codeSelectorTimeout, "上传文件失败")
+}
+
+// drag <src> <dst>
+func cmdDrag(ctx context.Context, s *session, args []string) (any, error) {
+	if len(args) < 2 {
+		return nil, usageError("drag <src> <dst>")
+	}
+	src, err := s.resolveNode(ctx, args[0])
+	if err != nil {
+		return nil, err
+	}
+	dst, err := s.resolveNode(ctx, args[1])
+	if err != nil {
+		return nil, err
+	}
+	x1, y1, err := nodeCenter(ctx, src)
+	if err != nil {
+		return nil, wrapError(err, codeSelectorTimeout, "定位拖动源失败")
+	}
+	x2, y2, err := nodeCenter(ctx, dst)
+	if err != nil {
+		return nil, wrapError(err, codeSelectorTimeout, "定位拖动目标失败")
+	}
+	err = chromedp.Run(ctx,
+		chromedp.MouseEvent(input.MouseMoved, x1, y1),
+		chromedp.MouseEvent(input.MousePressed, x1, y1, chromedp.ButtonLeft, chromedp.ClickCount(1)),
+		chromedp.MouseEvent(input.MouseMoved, (x1+x2)/2, (y1+y2)/2, chromedp.ButtonLeft),
+		chromedp.MouseEvent(input.MouseMoved, x2, y2, chromedp.ButtonLeft),
+		chromedp.MouseEvent(input.MouseReleased, x2, y2, chromedp.ButtonLeft, chromedp.ClickCount(1)),
+	)
+	return nil, wrapError(err, codeSelectorTimeout, "拖动失败")
+}
+
+// scroll <up|down|left|right|top|bottom> [px]
+func cmdScroll(ctx context.Context, s *session, args []string) (any, error) {
+	if len(args) < 1 {
+		return nil, usageError("scroll <up|down|left|right|top|bottom> [px]")
+	}
+	px := 500
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			return nil, usageError("scroll <dir> [px]，px 须为非负整数")
+		}
+		px = n
+	}
+	var dx, dy string
+	switch args[0] {
+	case "up":
+		dx, dy = "0", strconv.Itoa(-px)
+	case "down":
+		dx, dy = "0", strconv.Itoa(px)
+	case "left":
+		dx, dy = strconv.Itoa(-px), "0"
+	case "right":
+		dx, dy = strconv.Itoa(px), "0"
+	case "top":
+		dx, dy = "0", "-document.documentElement.scrollHeight"
+	case "bottom":
+		dx, dy = "0", "document.documentElement.scrollHeight"
+	default:
+		return nil, usageError("scroll <up|down|left|right|top|bottom> [px]")
+	}
+	var pos struct {
+		X float64 `json:"x"`
+		Y float64 `json:"y"`
+	}
+	err := chromedp.Run(ctx, chromedp.Evaluate(
+		fmt.Sprintf(`window.scrollBy(%s, %s), ({x: window.scrollX, y: window.scrollY})`, dx, dy), &pos))
+	return pos, wrapError(err, codeSelectorTimeout, "滚动失败")
+}
+
+// scrollintoview <sel>
+func cmdScrollIntoView(ctx context.Context, s *session, args []string) (any, error) {
+	if len(args) < 1 {
+		return nil, usageError("scrollintoview <sel>")
+	}
+	id, err := s.resolveNode(ctx, args[0])
+	if err != nil {
+		return nil, err
+	}
+	err = chromedp.Run(ctx, dom.ScrollIntoViewIfNeeded().WithNodeID(id))
+	return nil, wrapError(err, codeSelectorTimeout, "滚动到元素失败")
+}
+
+// get text|html|value|attr|title|url|count|box [sel] [name]
+func cmdGet(ctx context.Context, s *session, args []string) (any, error) {
+	const usage = "get text|html|value|attr|title|url|count|box|table <sel> [name|format]"
+	if len(args) < 1 {
+		return nil, usageError(usage)
+	}
+	switch args[0] {
+	case "title":
+		var title string
+		err := chromedp.Run(ctx, chromedp.Title(&title))
+		return title, wrapError(err, codeSelectorTimeout, "获取标题失败")
+	case "url":
+		var url string
+		err := chromedp.Run(ctx, chromedp.Location(&url))
+		return url, wrapError(err, codeSelectorTimeout, "获取 URL 失败")
+	}
+
+	if len(args) < 2 {
+		return nil, usageError(usage)
+	}
+	sel := args[1]
+	switch args[0] {
+	case "count":
+		ids, err := s.resolveNodes(ctx, sel, false)
+		return len(ids), err
+	case "table":
+		return getTable(ctx, s, sel, args[2:])
+	}
+
+	var fn string
+	var fnArgs []any
+	switch args[0] {
+	case "text":
+		fn = `function() { return this.innerText !== undefined ? this.innerText : this.textContent; }`
+	case "html":
+		fn = `function() { return this.outerHTML; }`
+	case "value":
+		fn = `function() { return this.value === undefined ? null : String(this.value); }`
+	case "attr":
+		if len(args) < 3 {
+			return nil, usageError("get attr <sel> <name>")
+		}
+		fn = `function(name) { return this.getAttribute(name); }`
+		fnArgs = append(fnArgs, args[2])
+	case "box":
+		fn = `function() {
+			const r = this.getBoundingClientRect();
+			return {x: r.x, y: r.y, width: r.width, height: r.height};
+		}`
+	default:
+		return nil, usageError(usage)
+	}
+
+	id, err := s.resolveNode(ctx, sel)
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	err = callOnNode(ctx, id, fn, &value, fnArgs...)
+	return value, wrapError(err, codeSelectorTimeout, "读取元素失败")
+}
+
+// get table <sel> [markdown|csv|json]：sel 为表格或包含表格的元素，取其中第一个表格；
+// markdown（默认）与 csv 返回文本，json 返回表头与以表头为键的行
+func getTable(ctx context.Context, s *session, sel string, args []string) (any, error) {
+	format := tablesMarkdown
+	if len(args) > 0 {
+		format = strings.ToLower(args[0])
+	}
+	switch format {
+	case tablesMarkdown, tablesCSV, tablesJSON:
+	default:
+		return nil, usageError("get table <sel> [markdown|csv|json]")
+	}
+
+	id, err := s.resolveNode(ctx, sel)
+	if err != nil {
+		return nil, err
+	}
+	var outerHTML string
+	if err := callOnNode(ctx, id, `function() { return this.outerHTML; }`, &outerHTML); err != nil {
+		return nil, wrapError(err, codeSelectorTimeout, "读取元素失败")
+	}
+	// 把元素的 HTML 放入 body 中按完整文档解析
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader("<body>" + outerHTML + "</body>"))
+	if err != nil {
+		return nil, newError(codeInternal, "解析元素 HTML 失败", err)
+	}
+	node := doc.Find("table").First()
+	if node.Length() == 0 {
+		return nil, newError(codeNotFound, fmt.Sprintf("元素中没有表格: %s", sel), nil)
+	}
+	table, err := convertTable(node.Get(0), format)
+	if err != nil {
+		return nil, err
+	}
+	if table == nil {
+		return nil, newError(codeNotFound, fmt.Sprintf("表格为空: %s", sel), nil)
+	}
+	switch format {
+	case tablesCSV:
+		return table.CSV, nil
+	case tablesJSON:
+		return map[string]any{"caption": table.Caption, "headers": table.Headers, "rows": table.Rows}, nil
+	}
+	return table.Markdown, nil
+}
+
+// is visible|enabled|checked <sel>；元素不存在时返回 false
+func cmdIs(ctx context.Context, s *session, args []string) (any, error) {
+	if len(args) < 2 {
+		return nil, usageError("is visible|enabled|checked <sel>")
+	}
+	var fn string
+	switch args[0] {
+	case "visible":
+		fn = `function() {
+			const style = window.getComputedStyle(this);
+			const r = this.getBoundingClientRect();
+			return style.display !== 'none' && style.visibility !== 'hidden' &&
+				style.opacity !== '0' && r.width > 0 && r.height > 0;
+		}`
+	case "enabled":
+		fn = `function() { return !this.disabled && this.getAttribute('aria-disabled') !== 'true'; }`
+	case "checked":
+		fn = `function() { return !!this.checked || this.getAttribute('aria-checked') === 'true'; }`
+	default:
+		return nil, usageError("is visible|enabled|checked <sel>")
+	}
+
+	ids, err := s.resolveNodes(ctx, args[1], false)
+	if err != nil || len(ids) == 0 {
+		return false, err
+	}
+	var ok bool
+	err = callOnNode(ctx, ids[0], fn, &ok)
+	return ok, wrapError(err, codeSelectorTimeout, "读取元素状态失败")
+}
+
+// find 在页面中定位元素并打上临时标记，返回可用于后续查询的 CSS 选择器
+const findElementJS = `function(kind, value, name) {
+	const doc = this.nodeType === 9 ? this : (this.contentDocument || this.ownerDocument);
+	const norm = s => (s || '').replace(/\s+/g, ' ').trim().toLowerCase();
+	const want = norm(value);
+	const wantName = norm(name);
+	const visible = el => {
+		const r = el.getBoundingClientRect();
+		const style = doc.defaultView.getComputedStyle(el);
+		return r.width > 0 && r.height > 0 && style.visibility !== 'hidden' && style.display !== 'none';
+	};
+	const roles = {
+		button: 'button, input[type=button], input[type=submit], input[type=reset], summary',
+		link: 'a[href], area[href]',
+		textbox: 'input:not([type]), input[type=text], input[type=email], input[type=search], input[type=url], input[type=tel], input[type=password], input[type=number], textarea, [contenteditable=""], [contenteditable=true]',
+		checkbox: 'input[type=checkbox]',
+		radio: 'input[type=radio]',
+		combobox: 'select',
+		heading: 'h1, h2, h3, h4, h5, h6',
+		img: 'img',
+		listitem: 'li',
+		option: 'option',
+		table: 'table',
+	};
+	const accessibleName = el => norm(
+		el.getAttribute('aria-label') || (el.labels && el.labels[0] && el.labels[0].innerText) ||
+		el.innerText || el.value || el.getAttribute('title') || el.getAttribute('alt') || el.getAttribute('placeholder'));
+
+	let candidates = [];
+	switch (kind) {
+	case 'role': {
+		const sel = '[role="' + CSS.escape(value) + '"]' + (roles[want] ? ', ' + roles[want] : '');
+		candidates = Array.from(doc.querySelectorAll(sel));
+		if (wantName) {
+			const exact = candidates.filter(el => accessibleName(el) === wantName);
+			candidates = exact.length ? exact : candidates.filter(el => accessibleName(el).includes(wantName));
+		}
+		break;
+	}
+	case 'text': {
+		const all = Array.from(doc.body.querySelectorAll('*'))
+			.filter(el => !el.matches('script, style, noscript') && norm(el.innerText).includes(want));
+		// 只保留最内层的匹配元素，精确匹配优先
+		const leaves = all.filter(el => !all.some(other => other !== el && el.contains(other)));
+		candidates = leaves.filter(el => norm(el.innerText) === want).concat(leaves.filter(el => norm(el.innerText) !== want));
+		break;
+	}
+	case 'label': {
+		for (const label of doc.querySelectorAll('label')) {
+			if (norm(label.innerText).includes(want) && label.control) {
+				candidates.push(label.control);
+			}
+		}
+		for (const el of doc.querySelectorAll('[aria-label]')) {
+			if (norm(el.getAttribute('aria-label')).includes(want)) {
+				candidates.push(el);
+			}
+		}
+		break;
+	}
+	case 'placeholder':
+		candidates = Array.from(doc.querySelectorAll('[placeholder]'))
+			.filter(el => norm(el.getAttribute('placeholder')).includes(want));
+		break;
+	default:
+		throw new Error('unknown find kind: ' + kind);
+	}
+
+	const el = candidates.find(visible) || candidates[0];
+	if (!el) {
+		return '';
+	}
+	const win = doc.defaultView;
+	win.__servicorFindSeq = (win.__servicorFindSeq || 0) + 1;
+	const token = String(win.__servicorFindSeq);
+	el.setAttribute('data-servicor-find', token);
+	return '[data-servicor-find="' + token + '"]';
+}`
+
+// find role|text|label|placeholder <value> <action> [input] [--name <name>]
+func cmdFind(ctx context.Context, s *session, args []string) (any, error) {
+	const usage = "find role|text|label|placeholder <value> <action> [input] [--name <name>]"
+	var name string
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--name" && i+1 < len(args) {
+			name = args[i+1]
+			i++
+			continue
+		}
+		rest = append(rest, args[i])
+	}
+	if len(rest) < 3 {
+		return nil, usageError(usage)
+	}
+	kind, value, action := rest[0], rest[1], rest[2]
+
+	// 在当前 frame（或主文档）中执行查找；主文档通过 runtime.Evaluate 取得，
+	// 避免 DOM.getDocument 使 chromedp 缓存的节点失效
+	var sel string
+	var err error
+	if s.frame != nil {
+		err = callOnNode(ctx, s.frame.NodeID, findElementJS, &sel, kind, value, name)
+	} else {
+		err = chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+			doc, exp, err := runtime.Evaluate(`document`).Do(ctx)
+			if err != nil {
+				return err
+			}
+			if exp != nil {
+				return exp
+			}
+			defer runtime.ReleaseObject(doc.ObjectID).Do(ctx)
+			return callOnObject(ctx, doc.ObjectID, findElementJS, &sel, kind, value, name)
+		}))
+	}
+	if err != nil {
+		return nil, wrapError(err, codeSelectorTimeout, "查找元素失败")
+	}
+	if sel == "" {
+		return nil, newError(codeNotFound, fmt.Sprintf("找不到 %s 为 %q 的元素", kind, value), nil)
+	}
+
+	actionArgs := append([]string{sel}, rest[3:]...)
+	switch action {
+	case "text", "html", "value", "box":
+		return cmdGet(ctx, s, append([]string{action}, actionArgs...))
+	case "click", "dblclick", "fill", "type", "hover", "check", "uncheck", "select", "upload", "scrollintoview":
+		return browserCommands[action].run(ctx, s, actionArgs)
+	}
+	return nil, usageError(usage)
+}
@@ -0,0 +1,638 @@
+package main
+
+import "C"
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+	"github.com/chromedp/chromedp/device"
+)
+
+// 命令执行后读取页面状态的超时，避免页面卡住时拖慢返回
+const pageStateTimeout = 2 * time.Second
+
+// 浏览器命令
+type browserCommand struct {
+	run func(ctx context.Context, s *session, args []string) (any, error)
+	// 为 true 时不拆分参数，命令名之后的原始文本作为唯一参数传入（用于 JS 代码）
+	rawArgs bool
+}
+
+// 命令表，在 init 中填充以避免 find 与命令表之间的初始化循环
+var browserCommands map[string]browserCommand
+
+func init() {
+	browserCommands = map[string]browserCommand{
+		"open":           {run: cmdOpen},
+		"back":           {run: historyCommand("back")},
+		"forward":        {run: historyCommand("forward")},
+		"reload":         {run: historyCommand("reload")},
+		"click":          {run: cmdClick},
+		"dblclick":       {run: cmdDoubleClick},
+		"fill":           {run: cmdFill},
+		"type":           {run: cmdType},
+		"press":          {run: cmdPress},
+		"hover":          {run: cmdHover},
+		"select":         {run: cmdSelect},
+		"check":          {run: checkCommand(true)},
+		"uncheck":        {run: checkCommand(false)},
+		"upload":         {run: cmdUpload},
+		"drag":           {run: cmdDrag},
+		"scroll":         {run: cmdScroll},
+		"scrollintoview": {run: cmdScrollIntoView},
+		"get":            {run: cmdGet},
+		"is":             {run: cmdIs},
+		"find":           {run: cmdFind},
+		"eval":           {run: cmdEval, rawArgs: true},
+		"wait":           {run: cmdWait, rawArgs: true},
+		"tab":            {run: cmdTab},
+		"frame":          {run: cmdFrame},
+		"set":            {run: cmdSet},
+		"network":        {run: cmdNetwork},
+		"snapshot":       {run: cmdSnapshot},
+		"screenshot":     {run: cmdScreenshot},
+		"pdf":            {run: cmdPDF},
+		"cookies":        {run: cmdCookies},
+		"storage":        {run: cmdStorage},
+		"state":          {run: cmdState},
+	}
+}
+
+// BrowserExec 的返回数据
+type execResult struct {
+	Command string      `json:"command"`
+	Output  any         `json:"output,omitempty"`
+	URL     string      `json:"url,omitempty"`
+	Title   string      `json:"title,omitempty"`
+	Dialog  *dialogInfo `json:"dialog,omitempty"` // 页面上尚未处理的对话框
+}
+
+type dialogInfo struct {
+	Type          string `json:"type"`
+	Message       string `json:"message"`
+	DefaultPrompt string `json:"default_prompt,omitempty"`
+}
+
+func usageError(usage string) error {
+	return newError(codeInvalidArgument, "用法: "+usage, nil)
+}
+
+// 按 shell 风格拆分命令行，规则与 browser.rs 的 split_browser_command 一致
+func splitCommandLine(line string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	var quote rune
+	escaped := false
+	for _, ch := range line {
+		switch {
+		case escaped:
+			current.WriteRune(ch)
+			escaped = false
+		case ch == '\\':
+			escaped = true
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			} else {
+				current.WriteRune(ch)
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case unicode.IsSpace(ch):
+			if current.Len() > 0 {
+				args = append(args, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(ch)
+		}
+	}
+	if escaped {
+		current.WriteRune('\\')
+	}
+	if quote != 0 {
+		return nil, newError(codeInvalidArgument, "命令中存在未闭合的引号", nil)
+	}
+	if current.Len() > 0 {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// 返回命令名之后的原始文本
+func rawTail(line string) string {
+	line = strings.TrimSpace(line)
+	i := strings.IndexFunc(line, unicode.IsSpace)
+	if i < 0 {
+		return ""
+	}
+	return strings.TrimSpace(line[i:])
+}
+
+// 去掉包裹整段文本的一层引号
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// 读取当前标签页的 URL、标题与待处理的对话框
+func (s *session) describe(res *execResult) {
+	t := s.currentTab()
+	t.mu.Lock()
+	if ev := t.dialog; ev != nil {
+		res.Dialog = &dialogInfo{Type: string(ev.Type), Message: ev.Message, DefaultPrompt: ev.DefaultPrompt}
+	}
+	t.mu.Unlock()
+	res.URL, res.Title = t.location()
+}
+
+// 读取标签页的 URL 与标题；对话框打开时页面脚本无法执行，因此使用导航历史
+func (t *tab) location() (url, title string) {
+	ctx, cancel := context.WithTimeout(t.ctx, pageStateTimeout)
+	defer cancel()
+	err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		cur, entries, err := page.GetNavigationHistory().Do(ctx)
+		if err != nil {
+			return err
+		}
+		if cur >= 0 && cur < int64(len(entries)) {
+			url, title = entries[cur].URL, entries[cur].Title
+		}
+		return nil
+	}))
+	if err != nil && t.ctx.Err() == nil {
+		log.Printf("读取页面状态失败: %v", err)
+	}
+	return url, title
+}
+
+// 在会话中执行一条浏览器命令；timeout 为 0 时使用会话的单条命令超时
+func execCommand(s *session, line string, timeout time.Duration) (*execResult, error) {
+	args, err := splitCommandLine(line)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, newError(codeInvalidArgument, "命令为空", nil)
+	}
+	name := args[0]
+	res := &execResult{Command: name}
+
+	switch name {
+	case "close":
+		return res, closeSession(s.id)
+	case "dialog":
+		// 对话框会阻塞正在执行的命令，因此处理对话框时不获取会话锁
+		res.Output, err = cmdDialog(s, args[1:])
+		s.describe(res)
+		return res, err
+	}
+
+	cmd, ok := browserCommands[name]
+	if !ok {
+		return nil, newError(codeInvalidArgument, fmt.Sprintf("未知的命令: %s", name), nil)
+	}
+	if cmd.rawArgs {
+		args = nil
+		if tail := rawTail(line); tail != "" {
+			args = []string{tail}
+		}
+	} else {
+		args = args[1:]
+	}
+	if timeout <= 0 {
+		timeout = s.opts.timeout()
+	}
+	err = s.runTimeout(timeout, func(ctx context.Context) error {
+		var err error
+		res.Output, err = cmd.run(ctx, s, args)
+		return err
+	})
+	if s.ctx.Err() == nil {
+		s.describe(res)
+	}
+	return res, err
+}
+
+// 补全缺少协议的 URL
+func normalizeURL(raw string) string {
+	if strings.Contains(raw, "://") || strings.HasPrefix(raw, "about:") ||
+		strings.HasPrefix(raw, "data:") || strings.HasPrefix(raw, "file:") {
+		return raw
+	}
+	return "https://" + raw
+}
+
+// open <url> [--wait <方式>] [--max-wait <ms>]：打开页面，默认等待网络空闲
+func cmdOpen(ctx context.Context, s *session, args []string) (any, error) {
+	const usage = "open <url> [--wait networkidle|domcontentloaded|load] [--max-wait <ms>]"
+	if len(args) < 1 {
+		return nil, usageError(usage)
+	}
+	var opts loadOptions
+	for i := 1; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return nil, usageError(usage)
+		}
+		switch args[i] {
+		case "--wait":
+			opts.WaitUntil = args[i+1]
+		case "--max-wait":
+			ms, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return nil, usageError(usage)
+			}
+			opts.MaxWaitMs = ms
+		default:
+			return nil, usageError(usage)
+		}
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	if opts.WaitUntil == waitSelector || opts.WaitUntil == waitPredicate {
+		// 等待元素或条件使用 wait 命令
+		return nil, usageError(usage)
+	}
+
+	s.frame = nil
+	load, err := navigateAndWait(ctx, normalizeURL(args[0]), opts)
+	if err != nil {
+		return nil, err
+	}
+	if load.Response == nil {
+		return nil, nil
+	}
+	data := map[string]any{"status_code": load.Response.Status, "content_type": load.Response.MimeType}
+	if load.TimedOut {
+		data["wait_timed_out"] = true
+	}
+	return data, nil
+}
+
+// back / forward / reload
+func historyCommand(which string) func(context.Context, *session, []string) (any, error) {
+	return func(ctx context.Context, s *session, args []string) (any, error) {
+		s.frame = nil
+		var action chromedp.Action
+		switch which {
+		case "back":
+			action = chromedp.NavigateBack()
+		case "forward":
+			action = chromedp.NavigateForward()
+		default:
+			action = chromedp.Reload()
+		}
+		err := chromedp.Run(ctx, action)
+		return nil, wrapError(err, codeNavTimeout, "页面导航失败")
+	}
+}
+
+// eval <js>：在当前 frame 中执行 JS，等待 Promise 完成并返回结果
+func cmdEval(ctx context.Context, s *session, args []string) (any, error) {
+	if len(args) < 1 {
+		return nil, usageError("eval <js>")
+	}
+	script := unquote(args[0])
+
+	var value any
+	var err error
+	if s.frame != nil && s.frame.ContentDocument != nil {
+		err = callOnNode(ctx, s.frame.ContentDocument.NodeID,
+			`function(src) { return this.defaultView.eval(src); }`, &value, script)
+	} else {
+		err = chromedp.Run(ctx, chromedp.Evaluate(script, &value, func(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+			return p.WithAwaitPromise(true)
+		}))
+	}
+	return value, wrapError(err, codeScriptFailed, "执行脚本失败")
+}
+
+// wait <ms> | wait <sel> | wait --text <text> | wait --url <pattern> | wait --load | wait --fn <js>
+func cmdWait(ctx context.Context, s *session, args []string) (any, error) {
+	const usage = "wait <ms>|<sel>|--text <text>|--url <pattern>|--load|--fn <js>"
+	if len(args) < 1 {
+		return nil, usageError(usage)
+	}
+	raw := args[0]
+	if strings.HasPrefix(raw, "--fn") {
+		js := unquote(strings.TrimSpace(strings.TrimPrefix(raw, "--fn")))
+		if js == "" {
+			return nil, usageError("wait --fn <js>")
+		}
+		return nil, s.poll(ctx, js)
+	}
+
+	parts, err := splitCommandLine(raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) == 0 {
+		return nil, usageError(usage)
+	}
+	switch parts[0] {
+	case "--text":
+		if len(parts) < 2 {
+			return nil, usageError("wait --text <text>")
+		}
+		return nil, s.poll(ctx, `document.body && document.body.innerText.includes(`+jsString(strings.Join(parts[1:], " "))+`)`)
+	case "--url":
+		if len(parts) < 2 {
+			return nil, usageError("wait --url <pattern>")
+		}
+		re, err := compileURLPattern(parts[1])
+		if err != nil {
+			return nil, newError(codeInvalidArgument, "URL 模式无效", err)
+		}
+		return nil, s.poll(ctx, `new RegExp(`+jsString(re.String())+`).test(location.href)`)
+	case "--load":
+		return nil, s.poll(ctx, `document.readyState === 'complete'`)
+	}
+
+	if ms, err := strconv.Atoi(parts[0]); err == nil {
+		if ms < 0 {
+			return nil, usageError(usage)
+		}
+		return nil, wrapError(sleepContext(ctx, time.Duration(ms)*time.Millisecond), codeSelectorTimeout, "等待被中断")
+	}
+	sel := strings.Join(parts, " ")
+	query, opts, err := s.queryOptions(ctx, sel)
+	if err != nil {
+		return nil, err
+	}
+	err = chromedp.Run(ctx, chromedp.WaitVisible(query, opts...))
+	return nil, wrapError(err, codeSelectorTimeout, fmt.Sprintf("等待元素 %s 失败", sel))
+}
+
+// 在当前 frame 中轮询 JS 表达式直到为真，超时由 ctx 控制
+func (s *session) poll(ctx context.Context, expr string) error {
+	opts := []chromedp.PollOption{chromedp.WithPollingTimeout(0)}
+	if s.frame != nil {
+		opts = append(opts, chromedp.WithPollingInFrame(s.frame))
+	}
+	err := chromedp.Run(ctx, chromedp.Poll(expr, nil, opts...))
+	return wrapError(err, codeSelectorTimeout, "等待条件成立失败")
+}
+
+// 将 Go 字符串编码为 JS 字符串字面量
+func jsString(s string) string {
+	return strconv.Quote(s)
+}
+
+// 标签页信息
+type tabInfo struct {
+	Index   int    `json:"index"`
+	URL     string `json:"url"`
+	Title   string `json:"title"`
+	Current bool   `json:"current"`
+}
+
+// tab / tab new [url] / tab <n> / tab close [n]
+func cmdTab(ctx context.Context, s *session, args []string) (any, error) {
+	if len(args) == 0 {
+		s.tabsMu.Lock()
+		tabs := append([]*tab{}, s.tabs...)
+		current := s.current
+		s.tabsMu.Unlock()
+
+		infos := make([]tabInfo, 0, len(tabs))
+		for i, t := range tabs {
+			info := tabInfo{Index: i, Current: i == current}
+			info.URL, info.Title = t.location()
+			infos = append(infos, info)
+		}
+		return infos, nil
+	}
+
+	switch args[0] {
+	case "new":
+		tabCtx, cancel := chromedp.NewContext(s.ctx)
+		if err := chromedp.Run(tabCtx); err != nil {
+			cancel()
+			return nil, wrapError(err, codeNavTimeout, "打开新标签页失败")
+		}
+		s.addTab(tabCtx, cancel)
+		s.tabsMu.Lock()
+		s.current = len(s.tabs) - 1
+		index := s.current
+		s.tabsMu.Unlock()
+		s.frame = nil
+
+		if len(args) > 1 {
+			navCtx, cancelNav := context.WithTimeout(tabCtx, s.opts.timeout())
+			defer cancelNav()
+			if err := chromedp.Run(navCtx, chromedp.Navigate(normalizeURL(args[1]))); err != nil {
+				return map[string]int{"index": index}, wrapError(err, codeNavTimeout, "打开页面失败")
+			}
+		}
+		return map[string]int{"index": index}, nil
+
+	case "close":
+		s.tabsMu.Lock()
+		index := s.current
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 0 || n >= len(s.tabs) {
+				s.tabsMu.Unlock()
+				return nil, newError(codeNotFound, fmt.Sprintf("标签页不存在: %s", args[1]), nil)
+			}
+			index = n
+		}
+		if index == 0 {
+			s.tabsMu.Unlock()
+			// 首个标签页的 context 持有整个浏览器，关闭它须使用 close 命令
+			return nil, newError(codeInvalidArgument, "不能关闭首个标签页，请使用 close 关闭会话", nil)
+		}
+		t := s.tabs[index]
+		s.tabs = append(s.tabs[:index], s.tabs[index+1:]...)
+		if s.current == index {
+			s.current = index - 1
+			s.frame = nil
+		} else if s.current > index {
+			s.current--
+		}
+		s.tabsMu.Unlock()
+		t.cancel()
+		return map[string]int{"index": s.current}, nil
+	}
+
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, usageError("tab | tab new [url] | tab <n> | tab close [n]")
+	}
+	s.tabsMu.Lock()
+	if n < 0 || n >= len(s.tabs) {
+		s.tabsMu.Unlock()
+		return nil, newError(codeNotFound, fmt.Sprintf("标签页不存在: %d", n), nil)
+	}
+	t := s.tabs[n]
+	s.tabsMu.Unlock()
+
+	activateCtx, cancel := context.WithTimeout(t.ctx, s.opts.timeout())
+	defer cancel()
+	if err := chromedp.Run(activateCtx, page.BringToFront()); err != nil {
+		return nil, wrapError(err, codeNavTimeout, "切换标签页失败")
+	}
+	s.tabsMu.Lock()
+	s.current = n
+	s.tabsMu.Unlock()
+	s.frame = nil
+	return map[string]int{"index": n}, nil
+}
+
+// frame <sel> / frame main
+func cmdFrame(ctx context.Context, s *session, args []string) (any, error) {
+	if len(args) < 1 {
+		return nil, usageError("frame <sel> | frame main")
+	}
+	if args[0] == "main" {
+		s.frame = nil
+		return nil, nil
+	}
+	query, opts, err := s.queryOptions(ctx, args[0])
+	if err != nil {
+		return nil, err
+	}
+	var nodes []*cdp.Node
+	if err := chromedp.Run(ctx, chromedp.Nodes(query, &nodes, opts...)); err != nil {
+		return nil, wrapError(err, codeSelectorTimeout, fmt.Sprintf("查找 frame %s 失败", args[0]))
+	}
+	if len(nodes) == 0 {
+		return nil, newError(codeNotFound, fmt.Sprintf("frame 不存在: %s", args[0]), nil)
+	}
+	node := nodes[0]
+	if node.NodeName != "IFRAME" && node.NodeName != "FRAME" {
+		return nil, newError(codeInvalidArgument, fmt.Sprintf("元素不是 frame: %s", node.NodeName), nil)
+	}
+	s.frame = node
+	return nil, nil
+}
+
+// dialog accept [text] / dialog dismiss
+func cmdDialog(s *session, args []string) (any, error) {
+	if len(args) < 1 || (args[0] != "accept" && args[0] != "dismiss") {
+		return nil, usageError("dialog accept [text] | dialog dismiss")
+	}
+	if err := s.ctx.Err(); err != nil {
+		return nil, newError(codeCancelled, fmt.Sprintf("会话已关闭: %s", s.id), err)
+	}
+	t := s.currentTab()
+	t.mu.Lock()
+	pending := t.dialog
+	t.mu.Unlock()
+	if pending == nil {
+		return nil, newError(codeNotFound, "当前没有打开的对话框", nil)
+	}
+
+	params := page.HandleJavaScriptDialog(args[0] == "accept")
+	if args[0] == "accept" && len(args) > 1 {
+		params = params.WithPromptText(strings.Join(args[1:], " "))
+	}
+	ctx, cancel := context.WithTimeout(t.ctx, s.opts.timeout())
+	defer cancel()
+	if err := chromedp.Run(ctx, params); err != nil {
+		return nil, wrapError(err, codeNavTimeout, "处理对话框失败")
+	}
+	t.mu.Lock()
+	t.dialog = nil
+	t.mu.Unlock()
+	return dialogInfo{Type: string(pending.Type), Message: pending.Message}, nil
+}
+
+// set viewport <w> <h> / set device <name> / set media dark|light
+func cmdSet(ctx context.Context, s *session, args []string) (any, error) {
+	const usage = "set viewport <w> <h> | set device <name> | set media dark|light"
+	if len(args) < 2 {
+		return nil, usageError(usage)
+	}
+	switch args[0] {
+	case "viewport":
+		if len(args) < 3 {
+			return nil, usageError("set viewport <w> <h>")
+		}
+		w, errW := strconv.ParseInt(args[1], 10, 64)
+		h, errH := strconv.ParseInt(args[2], 10, 64)
+		if errW != nil || errH != nil || w <= 0 || h <= 0 {
+			return nil, usageError("set viewport <w> <h>，宽高须为正整数")
+		}
+		err := chromedp.Run(ctx, chromedp.EmulateViewport(w, h))
+		return nil, wrapError(err, codeNavTimeout, "设置视口失败")
+
+	case "device":
+		name := strings.Join(args[1:], " ")
+		for d := device.Reset + 1; d <= device.MotoG4landscape; d++ {
+			if strings.EqualFold(d.String(), name) {
+				err := chromedp.Run(ctx, chromedp.Emulate(d))
+				return d.String(), wrapError(err, codeNavTimeout, "模拟设备失败")
+			}
+		}
+		return nil, newError(codeNotFound, fmt.Sprintf("未知的设备: %s", name), nil)
+
+	case "media":
+		if args[1] != "dark" && args[1] != "light" {
+			return nil, usageError("set media dark|light")
+		}
+		err := chromedp.Run(ctx, emulation.SetEmulatedMedia().WithFeatures([]*emulation.MediaFeature{
+			{Name: "prefers-color-scheme", Value: args[1]},
+		}))
+		return nil, wrapError(err, codeNavTimeout, "设置配色方案失败")
+	}
+	return nil, usageError(usage)
+}
+
+// 导出浏览器命令执行：在会话的当前标签页上执行一条 browser 工具命令，
+// 语法与 src/tools/browser.rs 中的说明一致
+//
+// 返回结果信封 {ok, data, error}，data 为 {command, output, url, title, dialog}，调用方须以 FreeString 释放。
+//
+//export BrowserExec
+func BrowserExec(sessionID *C.char, commandLine *C.char) *C.char {
+	return browserExec(C.GoString(sessionID), C.GoString(commandLine), "")
+}
+
+// 导出可配置的浏览器命令执行
+//
+// optionsJSON 为 {timeout_secs}，可为空；timeout_secs 为本条命令的超时，0 表示使用会话创建时设置的超时。
+// 返回结果信封与 BrowserExec 相同，调用方须以 FreeString 释放。
+//
+//export BrowserExecWithOptions
+func BrowserExecWithOptions(sessionID *C.char, commandLine *C.char, optionsJSON *C.char) *C.char {
+	return browserExec(C.GoString(sessionID), C.GoString(commandLine), C.GoString(optionsJSON))
+}
+
+func browserExec(sessionID, line, optionsJSON string) *C.char {
+	var opts struct {
+		TimeoutSecs int `json:"timeout_secs"`
+	}
+	if strings.TrimSpace(optionsJSON) != "" {
+		if err := json.Unmarshal([]byte(optionsJSON), &opts); err != nil {
+			return toCResponse(nil, newError(codeInvalidArgument, "命令选项不是合法的 JSON", err))
+		}
+	}
+	if opts.TimeoutSecs < 0 {
+		return toCResponse(nil, newError(codeInvalidArgument, "timeout_secs 不能为负数", nil))
+	}
+	s, err := lookupSession(sessionID)
+	if err != nil {
+		return toCResponse(nil, err)
+	}
+	res, err := execCommand(s, line, time.Duration(opts.TimeoutSecs)*time.Second)
+	if err != nil {
+		log.Printf("会话 %s 执行命令失败: %s: %v", s.id, line, err)
+	}
+	return toCResponse(res, err)
+}
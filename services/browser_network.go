@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// 每个标签页最多保留的网络请求记录数
+const maxRecordedRequests = 500
+
+// 网络请求记录
+type requestRecord struct {
+	id       network.RequestID
+	URL      string `json:"url"`
+	Method   string `json:"method"`
+	Type     string `json:"type"`
+	Status   int    `json:"status,omitempty"`
+	MimeType string `json:"mime_type,omitempty"`
+	Failed   string `json:"failed,omitempty"`
+}
+
+// 网络请求拦截规则
+type networkRoute struct {
+	Pattern string `json:"pattern"`
+	Abort   bool   `json:"abort"`
+	Body    string `json:"body,omitempty"`
+	re      *regexp.Regexp
+}
+
+// 将 URL 通配模式编译为正则：* 匹配任意字符，不含 * 时按子串匹配
+func compileURLPattern(pattern string) (*regexp.Regexp, error) {
+	if !strings.Contains(pattern, "*") {
+		return regexp.Compile(regexp.QuoteMeta(pattern))
+	}
+	quoted := regexp.QuoteMeta(pattern)
+	return regexp.Compile("^" + strings.ReplaceAll(quoted, `\*`, ".*") + "$")
+}
+
+func (t *tab) recordRequest(ev *network.EventRequestWillBeSent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.requests = append(t.requests, &requestRecord{
+		id:     ev.RequestID,
+		URL:    ev.Request.URL,
+		Method: ev.Request.Method,
+		Type:   string(ev.Type),
+	})
+	if len(t.requests) > maxRecordedRequests {
+		t.requests = t.requests[len(t.requests)-maxRecordedRequests:]
+	}
+}
+
+// 按请求 ID 查找记录，调用方须持有 t.mu
+func (t *tab) findRequestLocked(id network.RequestID) *requestRecord {
+	for i := len(t.requests) - 1; i >= 0; i-- {
+		if t.requests[i].id == id {
+			return t.requests[i]
+		}
+	}
+	return nil
+}
+
+func (t *tab) recordResponse(ev *network.EventResponseReceived) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if r := t.findRequestLocked(ev.RequestID); r != nil {
+		r.Status = int(ev.Response.Status)
+		r.MimeType = ev.Response.MimeType
+	}
+}
+
+func (t *tab) recordFailure(ev *network.EventLoadingFailed) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if r := t.findRequestLocked(ev.RequestID); r != nil {
+		r.Failed = ev.ErrorText
+	}
+}
+
+// 按拦截规则回应被暂停的请求；没有匹配的规则时放行
+func (t *tab) handlePaused(ev *fetch.EventRequestPaused) {
+	c := chromedp.FromContext(t.ctx)
+	if c == nil || c.Target == nil {
+		return
+	}
+	ctx := cdp.WithExecutor(t.ctx, c.Target)
+
+	var route *networkRoute
+	t.mu.Lock()
+	for i := len(t.routes) - 1; i >= 0; i-- {
+		if t.routes[i].re.MatchString(ev.Request.URL) {
+			route = t.routes[i]
+			break
+		}
+	}
+	t.mu.Unlock()
+
+	var err error
+	switch {
+	case route == nil:
+		err = fetch.ContinueRequest(ev.RequestID).Do(ctx)
+	case route.Abort:
+		err = fetch.FailRequest(ev.RequestID, network.ErrorReasonBlockedByClient).Do(ctx)
+	default:
+		contentType := "text/plain; charset=utf-8"
+		if json.Valid([]byte(route.Body)) {
+			contentType = "application/json; charset=utf-8"
+		}
+		err = fetch.FulfillRequest(ev.RequestID, 200).
+			WithResponseHeaders([]*fetch.HeaderEntry{{Name: "Content-Type", Value: contentType}}).
+			WithBody(base64.StdEncoding.EncodeToString([]byte(route.Body))).
+			Do(ctx)
+	}
+	if err != nil && ctx.Err() == nil {
+		log.Printf("处理被拦截的请求失败: %s: %v", ev.Request.URL, err)
+	}
+}
+
+// network route <url> [--abort|--body <json>] / network unroute [url] / network requests [filter] [--clear]
+func cmdNetwork(ctx context.Context, s *session, args []string) (any, error) {
+	if len(args) == 0 {
+		return nil, usageError("network route <url> [--abort|--body <json>] | network unroute [url] | network requests [filter] [--clear]")
+	}
+	t := s.currentTab()
+	switch args[0] {
+	case "route":
+		if len(args) < 2 {
+			return nil, usageError("network route <url> [--abort|--body <json>]")
+		}
+		route := &networkRoute{Pattern: args[1]}
+		for i := 2; i < len(args); i++ {
+			switch args[i] {
+			case "--abort":
+				route.Abort = true
+			case "--body":
+				if i+1 >= len(args) {
+					return nil, usageError("network route <url> --body <json>")
+				}
+				i++
+				route.Body = args[i]
+			default:
+				return nil, usageError(fmt.Sprintf("未知的 route 参数: %s", args[i]))
+			}
+		}
+		re, err := compileURLPattern(route.Pattern)
+		if err != nil {
+			return nil, newError(codeInvalidArgument, "URL 模式无效", err)
+		}
+		route.re = re
+
+		// 命令在会话锁内串行执行，fetchEnabled 只会被本命令修改
+		t.mu.Lock()
+		needEnable := !t.fetchEnabled
+		t.mu.Unlock()
+		if needEnable {
+			err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+				return fetch.Enable().WithPatterns([]*fetch.RequestPattern{{URLPattern: "*"}}).Do(ctx)
+			}))
+			if err != nil {
+				return nil, wrapError(err, codeNavTimeout, "启用请求拦截失败")
+			}
+		}
+		t.mu.Lock()
+		t.routes = append(t.routes, route)
+		t.fetchEnabled = true
+		t.mu.Unlock()
+		return route, nil
+
+	case "unroute":
+		t.mu.Lock()
+		var kept []*networkRoute
+		if len(args) >= 2 {
+			for _, r := range t.routes {
+				if r.Pattern != args[1] {
+					kept = append(kept, r)
+				}
+			}
+		}
+		needDisable := len(kept) == 0 && t.fetchEnabled
+		t.mu.Unlock()
+		if needDisable {
+			err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+				return fetch.Disable().Do(ctx)
+			}))
+			if err != nil {
+				return nil, wrapError(err, codeNavTimeout, "停用请求拦截失败")
+			}
+		}
+		t.mu.Lock()
+		t.routes = kept
+		if needDisable {
+			t.fetchEnabled = false
+		}
+		t.mu.Unlock()
+		return map[string]int{"routes": len(kept)}, nil
+
+	case "requests":
+		filter := ""
+		clear := false
+		for _, arg := range args[1:] {
+			if arg == "--clear" {
+				clear = true
+			} else {
+				filter = arg
+			}
+		}
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		records := []requestRecord{}
+		for _, r := range t.requests {
+			if filter == "" || strings.Contains(r.URL, filter) {
+				records = append(records, *r)
+			}
+		}
+		if clear {
+			t.requests = nil
+		}
+		return records, nil
+	}
+	return nil, usageError(fmt.Sprintf("未知的 network 子命令: %s", args[0]))
+}
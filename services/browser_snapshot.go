@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/chromedp/cdproto/accessibility"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/dom"
+	"github.com/chromedp/chromedp"
+)
+
+// 快照中最多分配的引用数，避免超大页面的输出失控
+const maxSnapshotRefs = 2000
+
+// 快照分配的元素引用
+type elementRef struct {
+	tab        *tab
+	generation int // 分配时标签页的导航代数
+	backendID  cdp.BackendNodeID
+	Role       string `json:"role"`
+	Name       string `json:"name,omitempty"`
+}
+
+// 可交互的无障碍角色
+var interactiveRoles = map[string]bool{
+	"button":           true,
+	"checkbox":         true,
+	"combobox":         true,
+	"link":             true,
+	"listbox":          true,
+	"menuitem":         true,
+	"menuitemcheckbox": true,
+	"menuitemradio":    true,
+	"option":           true,
+	"radio":            true,
+	"searchbox":        true,
+	"slider":           true,
+	"spinbutton":       true,
+	"switch":           true,
+	"tab":              true,
+	"textbox":          true,
+	"treeitem":         true,
+}
+
+// 仅用于布局、本身没有语义的角色，紧凑模式下省略
+var structuralRoles = map[string]bool{
+	"generic":      true,
+	"none":         true,
+	"presentation": true,
+	"group":        true,
+	"paragraph":    true,
+	"LineBreak":    true,
+}
+
+// 快照选项
+type snapshotOptions struct {
+	interactive bool // 只输出可交互元素
+	compact     bool // 省略无名称的结构节点与重复文本
+	maxDepth    int  // 最大深度，0 表示不限
+}
+
+// 快照结果
+type snapshotResult struct {
+	Snapshot string                `json:"snapshot"`
+	Refs     map[string]elementRef `json:"refs"`
+}
+
+// 解析 @eN 或 ref=eN 形式的引用
+func parseRef(sel string) (string, bool) {
+	var ref string
+	switch {
+	case strings.HasPrefix(sel, "@"):
+		ref = sel[1:]
+	case strings.HasPrefix(sel, "ref="):
+		ref = sel[len("ref="):]
+	default:
+		return "", false
+	}
+	if len(ref) < 2 || ref[0] != 'e' {
+		return "", false
+	}
+	if _, err := strconv.Atoi(ref[1:]); err != nil {
+		return "", false
+	}
+	return ref, true
+}
+
+// 将快照引用解析为当前文档中的节点 ID；页面导航或元素被移除后返回 STALE_REF
+func (s *session) resolveRef(ctx context.Context, ref string) (cdp.NodeID, error) {
+	r, ok := s.refs[ref]
+	if !ok {
+		return 0, newError(codeNotFound, fmt.Sprintf("引用 @%s 不存在，请先执行 snapshot", ref), nil)
+	}
+	stale := newError(codeStaleRef, fmt.Sprintf("引用 @%s 已过期，请重新执行 snapshot", ref), nil)
+	if r.tab != s.currentTab() {
+		return 0, newError(codeStaleRef, fmt.Sprintf("引用 @%s 属于其他标签页", ref), nil)
+	}
+	r.tab.mu.Lock()
+	generation := r.tab.generation
+	r.tab.mu.Unlock()
+	if generation != r.generation {
+		return 0, stale
+	}
+
+	var ids []cdp.NodeID
+	err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		ids, err = dom.PushNodesByBackendIDsToFrontend([]cdp.BackendNodeID{r.backendID}).Do(ctx)
+		return err
+	}))
+	if err != nil || len(ids) == 0 || ids[0] == 0 {
+		return 0, stale
+	}
+	return ids[0], nil
+}
+
+// 读取无障碍值中的字符串或数字
+func axString(v *accessibility.Value) string {
+	if v == nil || len(v.Value) == 0 {
+		return ""
+	}
+	var out any
+	if err := json.Unmarshal(v.Value, &out); err != nil {
+		return ""
+	}
+	switch x := out.(type) {
+	case string:
+		return x
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+// 节点的状态标记，如 [checked] [disabled] [level=2]
+func axStates(n *accessibility.Node) []string {
+	var states []string
+	for _, p := range n.Properties {
+		value := axString(p.Value)
+		switch p.Name {
+		case accessibility.PropertyNameChecked, accessibility.PropertyNameSelected,
+			accessibility.PropertyNameExpanded, accessibility.PropertyNamePressed:
+			switch value {
+			case "true":
+				states = append(states, string(p.Name))
+			case "mixed":
+				states = append(states, string(p.Name)+"=mixed")
+			}
+		case accessibility.PropertyNameDisabled, accessibility.PropertyNameRequired,
+			accessibility.PropertyNameReadonly, accessibility.PropertyNameFocused:
+			if value == "true" {
+				states = append(states, string(p.Name))
+			}
+		case accessibility.PropertyNameLevel:
+			states = append(states, "level="+value)
+		}
+	}
+	return states
+}
+
+// 遍历无障碍树生成快照文本并分配引用
+type snapshotBuilder struct {
+	opts       snapshotOptions
+	nodes      map[accessibility.NodeID]*accessibility.Node
+	tab        *tab
+	generation int
+	refs       map[string]elementRef
+	out        strings.Builder
+}
+
+func (b *snapshotBuilder) walk(n *accessibility.Node, depth int, parentName string) {
+	role := axString(n.Role)
+	name := strings.TrimSpace(axString(n.Name))
+	printed := !n.Ignored && b.include(n, role, name, parentName)
+
+	if printed {
+		if b.opts.maxDepth > 0 && depth >= b.opts.maxDepth {
+			return
+		}
+		b.out.WriteString(strings.Repeat("  ", depth))
+		b.out.WriteString("- ")
+		if role == "StaticText" {
+			b.out.WriteString("text: ")
+			b.out.WriteString(name)
+		} else {
+			b.out.WriteString(role)
+			if name != "" {
+				b.out.WriteString(" " + strconv.Quote(name))
+			}
+			if n.BackendDOMNodeID != 0 && (interactiveRoles[role] || name != "") && len(b.refs) < maxSnapshotRefs {
+				ref := fmt.Sprintf("e%d", len(b.refs)+1)
+				b.refs[ref] = elementRef{
+					tab:        b.tab,
+					generation: b.generation,
+					backendID:  n.BackendDOMNodeID,
+					Role:       role,
+					Name:       name,
+				}
+				b.out.WriteString(" [ref=" + ref + "]")
+			}
+			for _, state := range axStates(n) {
+				b.out.WriteString(" [" + state + "]")
+			}
+			if value := axString(n.Value); value != "" && value != name {
+				b.out.WriteString(" [value=" + strconv.Quote(value) + "]")
+			}
+		}
+		b.out.WriteString("\n")
+		depth++
+		if name != "" {
+			parentName = name
+		}
+	}
+
+	for _, id := range n.ChildIDs {
+		if child, ok := b.nodes[id]; ok {
+			b.walk(child, depth, parentName)
+		}
+	}
+}
+
+// 判断节点是否输出
+func (b *snapshotBuilder) include(n *accessibility.Node, role, name, parentName string) bool {
+	if role == "InlineTextBox" {
+		return false
+	}
+	if b.opts.interactive {
+		return interactiveRoles[role]
+	}
+	if !b.opts.compact {
+		return role != "StaticText" || name != ""
+	}
+	switch {
+	case role == "StaticText":
+		// 与父节点名称相同的文本是重复信息
+		return name != "" && !strings.Contains(parentName, name)
+	case structuralRoles[role]:
+		return name != ""
+	}
+	return true
+}
+
+// snapshot [-i] [-c] [-d <depth>]
+func cmdSnapshot(ctx context.Context, s *session, args []string) (any, error) {
+	var opts snapshotOptions
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-i", "--interactive":
+			opts.interactive = true
+		case "-c", "--compact":
+			opts.compact = true
+		case "-d", "--depth":
+			if i+1 >= len(args) {
+				return nil, usageError("snapshot [-i] [-c] [-d <depth>]")
+			}
+			i++
+			n, err := strconv.Atoi(args[i])
+			if err != nil || n < 0 {
+				return nil, usageError("snapshot -d <depth>，depth 须为非负整数")
+			}
+			opts.maxDepth = n
+		default:
+			return nil, usageError("snapshot [-i] [-c] [-d <depth>]")
+		}
+	}
+
+	t := s.currentTab()
+	t.mu.Lock()
+	generation := t.generation
+	t.mu.Unlock()
+
+	var nodes []*accessibility.Node
+	err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		params := accessibility.GetFullAXTree()
+		if s.frame != nil && s.frame.FrameID != "" {
+			params = params.WithFrameID(s.frame.FrameID)
+		}
+		var err error
+		nodes, err = params.Do(ctx)
+		return err
+	}))
+	if err != nil {
+		return nil, wrapError(err, codeNavTimeout, "获取无障碍树失败")
+	}
+	if len(nodes) == 0 {
+		return nil, newError(codeNotFound, "页面没有无障碍树", nil)
+	}
+
+	b := &snapshotBuilder{
+		opts:       opts,
+		nodes:      make(map[accessibility.NodeID]*accessibility.Node, len(nodes)),
+		tab:        t,
+		generation: generation,
+		refs:       make(map[string]elementRef),
+	}
+	for _, n := range nodes {
+		b.nodes[n.NodeID] = n
+	}
+	for _, n := range nodes {
+		if _, ok := b.nodes[n.ParentID]; n.ParentID == "" || !ok {
+			b.walk(n, 0, "")
+		}
+	}
+
+	// 新快照使之前的引用全部失效
+	s.refs = b.refs
+	return snapshotResult{Snapshot: b.out.String(), Refs: b.refs}, nil
+}
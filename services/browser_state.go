@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/chromedp/cdproto/domstorage"
+	"github.com/chromedp/cdproto/storage"
+	"github.com/chromedp/chromedp"
+)
+
+// state save 保存的登录状态；格式与 Playwright 的 storageState 兼容，另保存 sessionStorage
+type browserState struct {
+	Cookies []cookieInfo  `json:"cookies"`
+	Origins []originState `json:"origins"`
+}
+
+// 一个源的 Web Storage
+type originState struct {
+	Origin         string         `json:"origin"`
+	LocalStorage   []storageEntry `json:"localStorage"`
+	SessionStorage []storageEntry `json:"sessionStorage,omitempty"`
+}
+
+type storageEntry struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// 按键排序，使保存的文件内容稳定
+func toStorageEntries(items map[string]string) []storageEntry {
+	entries := make([]storageEntry, 0, len(items))
+	for name, value := range items {
+		entries = append(entries, storageEntry{Name: name, Value: value})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries
+}
+
+// state save <path> | state load <path>：保存或恢复全部 cookie 与当前页面的源的 localStorage、sessionStorage
+func cmdState(ctx context.Context, s *session, args []string) (any, error) {
+	const usage = "state save <path> | state load <path>"
+	if len(args) != 2 {
+		return nil, usageError(usage)
+	}
+	switch args[0] {
+	case "save":
+		return saveState(ctx, args[1])
+	case "load":
+		return loadState(ctx, args[1])
+	}
+	return nil, usageError(usage)
+}
+
+func saveState(ctx context.Context, path string) (any, error) {
+	cookies, err := browserCookies(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	state := browserState{Cookies: make([]cookieInfo, 0, len(cookies)), Origins: []originState{}}
+	for _, c := range cookies {
+		state.Cookies = append(state.Cookies, toCookieInfo(c))
+	}
+
+	// 只能读取已打开页面的源的 Web Storage；没有打开网页时只保存 cookie
+	localID, err := storageID(ctx, true)
+	if err != nil && classifyError(err) != codeUnsupported {
+		return nil, err
+	}
+	if err == nil {
+		sessionID := *localID
+		sessionID.IsLocalStorage = false
+		var local, session map[string]string
+		err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+			if err := domstorage.Enable().Do(ctx); err != nil {
+				return err
+			}
+			var err error
+			if local, err = storageItems(ctx, localID); err != nil {
+				return err
+			}
+			session, err = storageItems(ctx, &sessionID)
+			return err
+		}))
+		if err != nil {
+			return nil, wrapError(err, codeScriptFailed, "读取 Web Storage 失败")
+		}
+		state.Origins = append(state.Origins, originState{
+			Origin:         localID.SecurityOrigin,
+			LocalStorage:   toStorageEntries(local),
+			SessionStorage: toStorageEntries(session),
+		})
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return nil, newError(codeInternal, "序列化登录状态失败", err)
+	}
+	// 登录状态中有凭据，只允许当前用户读写
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return nil, newError(codeIOError, fmt.Sprintf("无法写入状态文件 %s", path), err)
+	}
+	return map[string]any{"path": path, "cookies": len(state.Cookies), "origins": len(state.Origins)}, nil
+}
+
+func loadState(ctx context.Context, path string) (any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, newError(codeIOError, fmt.Sprintf("无法读取状态文件 %s", path), err)
+	}
+	var state browserState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, newError(codeInvalidArgument, "状态文件不是合法的 JSON", err)
+	}
+	params, err := parseJSONCookies(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(params) > 0 {
+		err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+			return storage.SetCookies(params).Do(ctx)
+		}))
+		if err != nil {
+			return nil, wrapError(err, codeNavTimeout, "导入 cookie 失败")
+		}
+	}
+
+	// 当前页面的源使用带存储键的 ID，其余的源只能按源写入，浏览器拒绝时跳过
+	current := map[bool]*domstorage.StorageID{}
+	for _, local := range []bool{true, false} {
+		if id, err := storageID(ctx, local); err == nil {
+			current[local] = id
+		}
+	}
+	restored := 0
+	skipped := []string{}
+	for _, o := range state.Origins {
+		err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+			if err := domstorage.Enable().Do(ctx); err != nil {
+				return err
+			}
+			for _, local := range []bool{true, false} {
+				entries := o.SessionStorage
+				if local {
+					entries = o.LocalStorage
+				}
+				if len(entries) == 0 {
+					continue
+				}
+				id := &domstorage.StorageID{SecurityOrigin: o.Origin, IsLocalStorage: local}
+				if keyed := current[local]; keyed != nil && keyed.SecurityOrigin == o.Origin {
+					id = keyed
+				}
+				for _, e := range entries {
+					if err := domstorage.SetDOMStorageItem(id, e.Name, e.Value).Do(ctx); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		}))
+		if err != nil {
+			log.Printf("恢复 %s 的 Web Storage 失败: %v", o.Origin, err)
+			skipped = append(skipped, o.Origin)
+			continue
+		}
+		restored++
+	}
+	return map[string]any{"path": path, "cookies": len(params), "origins": restored, "skipped_origins": skipped}, nil
+}
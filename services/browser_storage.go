@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/chromedp/cdproto/domstorage"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/storage"
+	"github.com/chromedp/chromedp"
+)
+
+// cookies [domain] | cookies set <name> <value> [options] | cookies clear [domain] |
+// cookies export <path> [--format netscape|json] [--domain <d>] | cookies import <path> [--format netscape|json]
+func cmdCookies(ctx context.Context, s *session, args []string) (any, error) {
+	const usage = "cookies [domain] | cookies set <name> <value> | cookies clear [domain] | cookies export <path> | cookies import <path>"
+	if len(args) == 0 {
+		return listCookies(ctx, "")
+	}
+	switch args[0] {
+	case "set":
+		return nil, setCookie(ctx, args[1:])
+	case "clear":
+		domain := ""
+		if len(args) > 1 {
+			domain = args[1]
+		}
+		return clearCookies(ctx, domain)
+	case "export", "import":
+		if len(args) < 2 {
+			return nil, usageError(fmt.Sprintf("cookies %s <path> [--format netscape|json]", args[0]))
+		}
+		path, format, domain := args[1], cookieFormatForPath(args[1]), ""
+		for i := 2; i < len(args); i++ {
+			if i+1 >= len(args) {
+				return nil, usageError(usage)
+			}
+			switch args[i] {
+			case "--format":
+				format = strings.ToLower(args[i+1])
+			case "--domain":
+				domain = args[i+1]
+			default:
+				return nil, usageError(fmt.Sprintf("未知的 cookies 参数: %s", args[i]))
+			}
+			i++
+		}
+		if format != cookieFormatNetscape && format != cookieFormatJSON {
+			return nil, usageError("cookies export|import <path> --format netscape|json")
+		}
+		var n int
+		var err error
+		if args[0] == "export" {
+			n, err = exportCookiesFile(ctx, path, format, domain)
+		} else {
+			n, err = importCookiesFile(ctx, path, format)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"path": path, "format": format, "count": n}, nil
+	}
+	if len(args) == 1 && !strings.HasPrefix(args[0], "-") {
+		return listCookies(ctx, args[0])
+	}
+	return nil, usageError(usage)
+}
+
+func listCookies(ctx context.Context, domain string) (any, error) {
+	cookies, err := browserCookies(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]cookieInfo, 0, len(cookies))
+	for _, c := range cookies {
+		infos = append(infos, toCookieInfo(c))
+	}
+	return infos, nil
+}
+
+// cookies set <name> <value> [--url <url>] [--domain <d>] [--path <p>] [--secure] [--httponly]
+// [--samesite strict|lax|none] [--expires <unix 秒>]；不指定 url 与 domain 时设置到当前页面
+func setCookie(ctx context.Context, args []string) error {
+	const usage = "cookies set <name> <value> [--url <url>] [--domain <d>] [--path <p>] [--secure] [--httponly] [--samesite strict|lax|none] [--expires <unix>]"
+	if len(args) < 2 {
+		return usageError(usage)
+	}
+	var url, domain, path, sameSite string
+	var secure, httpOnly bool
+	var expires float64
+	for i := 2; i < len(args); i++ {
+		switch args[i] {
+		case "--secure":
+			secure = true
+		case "--httponly":
+			httpOnly = true
+		case "--url", "--domain", "--path", "--samesite", "--expires":
+			if i+1 >= len(args) {
+				return usageError(usage)
+			}
+			i++
+			switch value := args[i]; args[i-1] {
+			case "--url":
+				url = value
+			case "--domain":
+				domain = value
+			case "--path":
+				path = value
+			case "--samesite":
+				sameSite = value
+			default:
+				n, err := strconv.ParseFloat(value, 64)
+				if err != nil || n < 0 {
+					return usageError("cookies set --expires <unix>，过期时间须为 Unix 秒数")
+				}
+				expires = n
+			}
+		default:
+			return usageError(fmt.Sprintf("未知的 cookies set 参数: %s", args[i]))
+		}
+	}
+
+	var param *network.CookieParam
+	if domain != "" {
+		p, err := newCookieParam(args[0], args[1], domain, path, false, secure, httpOnly, expires, sameSite)
+		if err != nil {
+			return newError(codeInvalidArgument, "cookie 不合法", err)
+		}
+		param = p
+	} else {
+		if url == "" {
+			if err := chromedp.Run(ctx, chromedp.Location(&url)); err != nil {
+				return wrapError(err, codeNavTimeout, "获取当前页面地址失败")
+			}
+		}
+		// 按 URL 设置时由浏览器确定域名，只对该主机有效
+		param = &network.CookieParam{Name: args[0], Value: args[1], URL: url, Path: path, Secure: secure, HTTPOnly: httpOnly}
+		setCookieExpiry(param, expires, sameSite)
+	}
+	err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		return storage.SetCookies([]*network.CookieParam{param}).Do(ctx)
+	}))
+	return wrapError(err, codeNavTimeout, "设置 cookie 失败")
+}
+
+// 删除属于 domain 的 cookie，domain 为空时删除全部，返回删除的数量
+func clearCookies(ctx context.Context, domain string) (any, error) {
+	if domain == "" {
+		cookies, err := browserCookies(ctx, "")
+		if err != nil {
+			return nil, err
+		}
+		err = chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+			return storage.ClearCookies().Do(ctx)
+		}))
+		return len(cookies), wrapError(err, codeNavTimeout, "清除 cookie 失败")
+	}
+
+	cookies, err := browserCookies(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	err = chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		for _, c := range cookies {
+			if err := network.DeleteCookies(c.Name).WithDomain(c.Domain).WithPath(c.Path).Do(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	return len(cookies), wrapError(err, codeNavTimeout, "清除 cookie 失败")
+}
+
+// storage local|session [key] | storage local|session set <key> <value> |
+// storage local|session remove <key> | storage local|session clear；作用于主框架页面的源
+func cmdStorage(ctx context.Context, s *session, args []string) (any, error) {
+	const usage = "storage local|session [key] | storage local|session set <k> <v> | storage local|session remove <k> | storage local|session clear"
+	if len(args) == 0 || (args[0] != "local" && args[0] != "session") {
+		return nil, usageError(usage)
+	}
+	id, err := storageID(ctx, args[0] == "local")
+	if err != nil {
+		return nil, err
+	}
+	args = args[1:]
+
+	var result any
+	err = chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		if err := domstorage.Enable().Do(ctx); err != nil {
+			return err
+		}
+		switch {
+		case len(args) == 3 && args[0] == "set":
+			return domstorage.SetDOMStorageItem(id, args[1], args[2]).Do(ctx)
+		case len(args) == 2 && args[0] == "remove":
+			return domstorage.RemoveDOMStorageItem(id, args[1]).Do(ctx)
+		case len(args) == 1 && args[0] == "clear":
+			return domstorage.Clear(id).Do(ctx)
+		case len(args) > 1:
+			return usageError(usage)
+		}
+
+		items, err := storageItems(ctx, id)
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			result = items
+			return nil
+		}
+		// 键不存在时返回 null
+		if value, ok := items[args[0]]; ok {
+			result = value
+		}
+		return nil
+	}))
+	if err != nil {
+		return nil, wrapError(err, codeScriptFailed, "读写 Web Storage 失败")
+	}
+	return result, nil
+}
+
+// 读取 Web Storage 中的所有项，须在 chromedp.ActionFunc 中调用
+func storageItems(ctx context.Context, id *domstorage.StorageID) (map[string]string, error) {
+	entries, err := domstorage.GetDOMStorageItems(id).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	items := make(map[string]string, len(entries))
+	for _, e := range entries {
+		if len(e) == 2 {
+			items[e[0]] = e[1]
+		}
+	}
+	return items, nil
+}
+
+// 当前主框架页面的 Web Storage；优先使用存储键，浏览器不支持时使用页面的源
+func storageID(ctx context.Context, local bool) (*domstorage.StorageID, error) {
+	var origin string
+	if err := chromedp.Run(ctx, chromedp.Evaluate(`location.origin`, &origin)); err != nil {
+		return nil, wrapError(err, codeScriptFailed, "获取页面的源失败")
+	}
+	if origin == "" || origin == "null" {
+		return nil, newError(codeUnsupported, "当前页面没有可用的 Web Storage，请先打开网页", nil)
+	}
+	id := &domstorage.StorageID{SecurityOrigin: origin, IsLocalStorage: local}
+	_ = chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		tree, err := page.GetFrameTree().Do(ctx)
+		if err != nil {
+			return err
+		}
+		key, err := storage.GetStorageKeyForFrame(tree.Frame.ID).Do(ctx)
+		if err != nil {
+			return err
+		}
+		id.StorageKey = domstorage.SerializedStorageKey(key)
+		return nil
+	}))
+	return id, nil
+}
@@ -7,10 +7,10 @@ case "$(uname)" in
         CGO_ENABLED=0
         GOOS=linux
         GOARCH=amd64
-        go build -buildmode=c-archive -ldflags '-extldflags "-static"' -o libservico.a main.go
+        go build -buildmode=c-archive -ldflags '-extldflags "-static"' -o libservico.a .
         ;;
     *)
         echo "Building for native platform"
-        go build -buildmode=c-archive -o libservico.a main.go
+        go build -buildmode=c-archive -o libservico.a .
         ;;
 esac
@@ -0,0 +1,385 @@
+package main
+
+import "C"
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// 截图格式
+const (
+	imagePNG  = "png"
+	imageJPEG = "jpeg"
+)
+
+// 未指定时 JPEG 的质量
+const defaultJPEGQuality = 80
+
+// 纸张尺寸（英寸，纵向）
+var paperSizes = map[string][2]float64{
+	"letter":  {8.5, 11},
+	"legal":   {8.5, 14},
+	"tabloid": {11, 17},
+	"ledger":  {17, 11},
+	"a3":      {11.69, 16.54},
+	"a4":      {8.27, 11.69},
+	"a5":      {5.83, 8.27},
+}
+
+// 长度单位对应的英寸数
+var lengthUnits = map[string]float64{
+	"in": 1,
+	"cm": 1 / 2.54,
+	"mm": 1 / 25.4,
+	"pt": 1.0 / 72,
+	"px": 1.0 / 96,
+}
+
+// 截图选项
+type screenshotOptions struct {
+	loadOptions
+	FullPage bool   `json:"full_page"` // 截取整个页面，而不只是视口
+	Selector string `json:"selector"`  // 只截取该元素所占的区域
+	Format   string `json:"format"`    // png 或 jpeg；为空时按 path 的扩展名确定，默认 png
+	Quality  int    `json:"quality"`   // JPEG 的质量，1 到 100，0 表示默认值 80
+	Path     string `json:"path"`      // 保存的文件路径，为空时以 base64 返回
+}
+
+// PDF 选项，长度可带 in、cm、mm、pt 或 px 单位，不带单位时为英寸
+type pdfOptions struct {
+	loadOptions
+	Paper  string `json:"paper"`  // letter（默认）、legal、tabloid、ledger、a3、a4 或 a5
+	Width  string `json:"width"`  // 自定义纸张宽度，覆盖 paper
+	Height string `json:"height"` // 自定义纸张高度，覆盖 paper
+	// 页边距，与 CSS 的 margin 相同，可写 1 到 4 个值，如 "1cm" 或 "10mm 15mm"；为空时使用浏览器的默认值
+	Margin          string  `json:"margin"`
+	Landscape       bool    `json:"landscape"`
+	PrintBackground bool    `json:"print_background"` // 打印背景颜色与图片
+	Scale           float64 `json:"scale"`            // 缩放比例，0.1 到 2，0 表示 1
+	PageRanges      string  `json:"page_ranges"`      // 如 "1-5, 8"，为空时打印所有页
+	Path            string  `json:"path"`             // 保存的文件路径，为空时以 base64 返回
+}
+
+// 截图或 PDF 的结果；保存到文件时 data 为空
+type captureResult struct {
+	Path     string `json:"path,omitempty"`
+	Data     string `json:"data,omitempty"` // base64 编码的内容
+	MimeType string `json:"mime_type"`
+	Bytes    int    `json:"bytes"`
+	Width    int    `json:"width,omitempty"` // 截图的像素尺寸
+	Height   int    `json:"height,omitempty"`
+}
+
+func (o *screenshotOptions) validate() error {
+	o.Format = strings.ToLower(strings.TrimSpace(o.Format))
+	switch o.Format {
+	case "":
+		o.Format = imagePNG
+		if ext := strings.ToLower(filepath.Ext(o.Path)); ext == ".jpg" || ext == ".jpeg" {
+			o.Format = imageJPEG
+		}
+	case "jpg":
+		o.Format = imageJPEG
+	case imagePNG, imageJPEG:
+	default:
+		return newError(codeInvalidArgument, fmt.Sprintf("format 须为 png 或 jpeg: %s", o.Format), nil)
+	}
+	if o.Quality < 0 || o.Quality > 100 {
+		return newError(codeInvalidArgument, "quality 须在 1 到 100 之间", nil)
+	}
+	if o.Quality == 0 {
+		o.Quality = defaultJPEGQuality
+	}
+	return nil
+}
+
+// 按选项构造打印参数
+func (o *pdfOptions) params() (*page.PrintToPDFParams, error) {
+	p := page.PrintToPDF().
+		WithLandscape(o.Landscape).
+		WithPrintBackground(o.PrintBackground).
+		WithPageRanges(strings.TrimSpace(o.PageRanges))
+
+	if paper := strings.ToLower(strings.TrimSpace(o.Paper)); paper != "" {
+		size, ok := paperSizes[paper]
+		if !ok {
+			return nil, newError(codeInvalidArgument,
+				fmt.Sprintf("paper 须为 letter、legal、tabloid、ledger、a3、a4 或 a5: %s", o.Paper), nil)
+		}
+		p = p.WithPaperWidth(size[0]).WithPaperHeight(size[1])
+	}
+	if o.Width != "" {
+		w, err := parseLength(o.Width)
+		if err != nil {
+			return nil, err
+		}
+		p = p.WithPaperWidth(w)
+	}
+	if o.Height != "" {
+		h, err := parseLength(o.Height)
+		if err != nil {
+			return nil, err
+		}
+		p = p.WithPaperHeight(h)
+	}
+
+	if strings.TrimSpace(o.Margin) != "" {
+		var sides []float64
+		for _, v := range strings.Fields(o.Margin) {
+			n, err := parseLength(v)
+			if err != nil {
+				return nil, err
+			}
+			sides = append(sides, n)
+		}
+		// 按 CSS 的规则展开为上、右、下、左
+		switch len(sides) {
+		case 1:
+			sides = []float64{sides[0], sides[0], sides[0], sides[0]}
+		case 2:
+			sides = []float64{sides[0], sides[1], sides[0], sides[1]}
+		case 3:
+			sides = []float64{sides[0], sides[1], sides[2], sides[1]}
+		case 4:
+		default:
+			return nil, newError(codeInvalidArgument, fmt.Sprintf("margin 须为 1 到 4 个长度: %s", o.Margin), nil)
+		}
+		p = p.WithMarginTop(sides[0]).WithMarginRight(sides[1]).WithMarginBottom(sides[2]).WithMarginLeft(sides[3])
+	}
+
+	if o.Scale != 0 {
+		if o.Scale < 0.1 || o.Scale > 2 {
+			return nil, newError(codeInvalidArgument, "scale 须在 0.1 到 2 之间", nil)
+		}
+		p = p.WithScale(o.Scale)
+	}
+	return p, nil
+}
+
+// 解析长度，返回英寸数
+func parseLength(s string) (float64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	number, unit := s, "in"
+	for u := range lengthUnits {
+		if strings.HasSuffix(s, u) {
+			number, unit = strings.TrimSpace(strings.TrimSuffix(s, u)), u
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n < 0 || math.IsInf(n, 0) {
+		return 0, newError(codeInvalidArgument, fmt.Sprintf("长度不合法: %s", s), nil)
+	}
+	return n * lengthUnits[unit], nil
+}
+
+// 截取当前页面；node 不为 0 时只截取该元素所占的区域
+func captureScreenshot(ctx context.Context, opts screenshotOptions, node cdp.NodeID) ([]byte, error) {
+	var clip *page.Viewport
+	switch {
+	case node != 0:
+		// 元素在文档中的位置，截图时包含视口之外的部分
+		var box struct{ X, Y, Width, Height float64 }
+		err := callOnNode(ctx, node, `function() {
+			const r = this.getBoundingClientRect();
+			return {x: r.left + window.scrollX, y: r.top + window.scrollY, width: r.width, height: r.height};
+		}`, &box)
+		if err != nil {
+			return nil, wrapError(err, codeSelectorTimeout, "读取元素位置失败")
+		}
+		if box.Width <= 0 || box.Height <= 0 {
+			return nil, newError(codeNotFound, "元素没有可见区域", nil)
+		}
+		clip = &page.Viewport{X: box.X, Y: box.Y, Width: box.Width, Height: box.Height, Scale: 1}
+	case opts.FullPage:
+		err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+			_, _, _, _, _, content, err := page.GetLayoutMetrics().Do(ctx)
+			if err != nil {
+				return err
+			}
+			clip = &page.Viewport{Width: math.Ceil(content.Width), Height: math.Ceil(content.Height), Scale: 1}
+			return nil
+		}))
+		if err != nil {
+			return nil, wrapError(err, codeNavTimeout, "读取页面尺寸失败")
+		}
+	}
+
+	params := page.CaptureScreenshot().WithFormat(page.CaptureScreenshotFormatPng)
+	if opts.Format == imageJPEG {
+		params = page.CaptureScreenshot().WithFormat(page.CaptureScreenshotFormatJpeg).WithQuality(int64(opts.Quality))
+	}
+	if clip != nil {
+		params = params.WithClip(clip).WithCaptureBeyondViewport(true)
+	}
+	var buf []byte
+	err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		buf, err = params.Do(ctx)
+		return err
+	}))
+	if err != nil {
+		return nil, wrapError(err, codeNavTimeout, "截图失败")
+	}
+	return buf, nil
+}
+
+// 把当前页面打印为 PDF，只有无头模式的浏览器支持
+func capturePDF(ctx context.Context, params *page.PrintToPDFParams) ([]byte, error) {
+	var buf []byte
+	err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		buf, _, err = params.Do(ctx)
+		return err
+	}))
+	if err != nil {
+		return nil, wrapError(err, codeNavTimeout, "生成 PDF 失败")
+	}
+	return buf, nil
+}
+
+// 把截图或 PDF 保存到 path，path 为空时以 base64 返回
+func saveCapture(data []byte, mimeType, path string) (*captureResult, error) {
+	result := &captureResult{MimeType: mimeType, Bytes: len(data)}
+	if strings.HasPrefix(mimeType, "image/") {
+		if config, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+			result.Width, result.Height = config.Width, config.Height
+		}
+	}
+	if path == "" {
+		result.Data = base64.StdEncoding.EncodeToString(data)
+		return result, nil
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return nil, newError(codeIOError, fmt.Sprintf("无法写入文件 %s", path), err)
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	result.Path = path
+	return result, nil
+}
+
+// 在新的浏览器中打开页面，加载完成后执行 capture
+func runCapture(url string, load loadOptions, capture func(ctx context.Context) (*captureResult, error)) (*captureResult, error) {
+	ctxTimeout, cancelTimeout := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancelTimeout()
+	ctx, cancel := newBrowserContext(ctxTimeout, browserOptions{})
+	defer cancel()
+	if err := startBrowser(ctx); err != nil {
+		return nil, err
+	}
+
+	if _, err := navigateAndWait(ctx, url, load); err != nil {
+		log.Printf("访问失败: %v", err)
+		return nil, err
+	}
+	return capture(ctx)
+}
+
+func runScreenshot(url string, opts screenshotOptions) (*captureResult, error) {
+	return runCapture(url, opts.loadOptions, func(ctx context.Context) (*captureResult, error) {
+		var node cdp.NodeID
+		if opts.Selector != "" {
+			query, queryOpts := selectorQuery(opts.Selector)
+			var ids []cdp.NodeID
+			if err := chromedp.Run(ctx, chromedp.NodeIDs(query, &ids, queryOpts...)); err != nil {
+				return nil, wrapError(err, codeSelectorTimeout, fmt.Sprintf("查找元素 %s 失败", opts.Selector))
+			}
+			node = ids[0]
+		}
+		data, err := captureScreenshot(ctx, opts, node)
+		if err != nil {
+			return nil, err
+		}
+		return saveCapture(data, "image/"+opts.Format, opts.Path)
+	})
+}
+
+func runPrintPDF(url string, opts pdfOptions) (*captureResult, error) {
+	params, err := opts.params()
+	if err != nil {
+		return nil, err
+	}
+	return runCapture(url, opts.loadOptions, func(ctx context.Context) (*captureResult, error) {
+		data, err := capturePDF(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		return saveCapture(data, "application/pdf", opts.Path)
+	})
+}
+
+// 解析 optionsJSON 到 v，为空时保留默认值
+func parseCaptureOptions(raw string, v any) error {
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(raw), v); err != nil {
+		return newError(codeInvalidArgument, "选项不是合法的 JSON", err)
+	}
+	return nil
+}
+
+// 导出截图功能
+//
+// optionsJSON 可为空，此时截取视口并以 base64 返回 PNG；full_page 截取整个页面，selector 只截取该元素，
+// format 与 quality 指定 PNG 或 JPEG 及其质量，path 指定保存的文件。加载选项与 VisitWithOptions 相同。
+// 返回结果信封 {ok, data, error}，data 为 {path, data, mime_type, bytes, width, height}，调用方须以 FreeString 释放。
+//
+//export Screenshot
+func Screenshot(url *C.char, optionsJSON *C.char) *C.char {
+	goURL := C.GoString(url)
+	if strings.TrimSpace(goURL) == "" {
+		return toCResponse(nil, newError(codeInvalidArgument, "URL 为空", nil))
+	}
+	var opts screenshotOptions
+	if err := parseCaptureOptions(C.GoString(optionsJSON), &opts); err != nil {
+		return toCResponse(nil, err)
+	}
+	if err := opts.loadOptions.validate(); err != nil {
+		return toCResponse(nil, err)
+	}
+	if err := opts.validate(); err != nil {
+		return toCResponse(nil, err)
+	}
+	return toCResponse(runScreenshot(goURL, opts))
+}
+
+// 导出打印 PDF 功能
+//
+// optionsJSON 可为空，此时按浏览器的默认设置打印并以 base64 返回；paper、width、height、margin、landscape、
+// print_background、scale 与 page_ranges 控制版面，path 指定保存的文件。加载选项与 VisitWithOptions 相同。
+// 返回结果信封 {ok, data, error}，data 为 {path, data, mime_type, bytes}，调用方须以 FreeString 释放。
+//
+//export PrintPDF
+func PrintPDF(url *C.char, optionsJSON *C.char) *C.char {
+	goURL := C.GoString(url)
+	if strings.TrimSpace(goURL) == "" {
+		return toCResponse(nil, newError(codeInvalidArgument, "URL 为空", nil))
+	}
+	var opts pdfOptions
+	if err := parseCaptureOptions(C.GoString(optionsJSON), &opts); err != nil {
+		return toCResponse(nil, err)
+	}
+	if err := opts.loadOptions.validate(); err != nil {
+		return toCResponse(nil, err)
+	}
+	return toCResponse(runPrintPDF(goURL, opts))
+}
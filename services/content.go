@@ -0,0 +1,590 @@
+package main
+
+import (
+	"math"
+	net_url "net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// 提取范围
+const (
+	extractPage = "page" // 整个页面
+	extractMain = "main" // 得分最高的正文容器及其相关的兄弟块
+	// 不返回正文，只返回标题大纲与链接，见 pageOutline
+	extractLinks = "links"
+)
+
+// 输出格式
+const (
+	formatText     = "text"     // 纯文本，每段一行
+	formatMarkdown = "markdown" // 保留标题、列表、链接、代码块与表格
+)
+
+// 正文提取选项
+type contentOptions struct {
+	Extract string
+	Format  string
+	// 返回 true 的容器不能作为正文，用于排除站点特有的信息栏、导航条
+	SkipCandidate func(text string) bool
+}
+
+// 在页面中执行，返回去掉了不可见元素的 HTML；只检查 body 内的元素，保留 head 中的元信息
+const visibleHTMLScript = `(() => {
+	const clone = document.documentElement.cloneNode(true);
+	const cloneBody = clone.querySelector('body');
+	if (document.body && cloneBody) {
+		const live = document.body.querySelectorAll('*');
+		const copies = cloneBody.querySelectorAll('*');
+		const hidden = [];
+		for (let i = 0; i < live.length && i < copies.length; i++) {
+			const style = window.getComputedStyle(live[i]);
+			if (style.display === 'none' || style.visibility === 'hidden' || style.opacity === '0') {
+				hidden.push(copies[i]);
+			}
+		}
+		hidden.forEach(el => el.remove());
+	}
+	return '<!DOCTYPE html>' + clone.outerHTML;
+})()`
+
+var (
+	// 不会包含正文的元素
+	removedTags = "script, style, noscript, template, iframe, object, embed, canvas, svg, button, input, select, textarea, link, meta"
+	// 提取正文时去掉的页面框架
+	chromeRoles = `[role="navigation"], [role="complementary"], [role="contentinfo"], [role="banner"], [role="dialog"], [role="alertdialog"]`
+
+	// class 与 id 中表明元素不太可能是正文的词
+	unlikelyCandidateRe = regexp.MustCompile(`(?i)banner|breadcrumb|combx|comment|community|consent|cookie|disqus|footer|gdpr|header|menu|modal|pager|pagination|popup|related|remark|replies|rss|share|shoutbox|sidebar|skyscraper|social|sponsor|subscribe|newsletter|ad-break|agegate`)
+	// 与上面同时出现时仍保留
+	maybeCandidateRe = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow|chapter|post|entry`)
+	// 为正文容器加分、减分的词
+	positiveRe = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|post|text|blog|story|chapter`)
+	negativeRe = regexp.MustCompile(`(?i)-ad-|hidden|banner|combx|comment|com-|contact|foot|footer|footnote|gdpr|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget|cookie|consent|nav|menu`)
+	// 行内样式隐藏的元素
+	hiddenStyleRe = regexp.MustCompile(`(?i)display\s*:\s*none|visibility\s*:\s*hidden`)
+
+	// 代码块的语言
+	codeLanguageRe = regexp.MustCompile(`(?:^|\s)(?:language|lang)-([\w+#-]+)`)
+	// 连续的空白
+	spaceRe = regexp.MustCompile(`[^\S\n]+`)
+)
+
+// 渲染 Markdown 时作为块处理的元素
+var blockTags = map[atom.Atom]bool{
+	atom.Html: true, atom.Body: true, atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true,
+	atom.Main: true, atom.Header: true, atom.Footer: true, atom.Nav: true, atom.Aside: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Pre: true, atom.Blockquote: true, atom.Ul: true, atom.Ol: true, atom.Li: true, atom.Dl: true,
+	atom.Dt: true, atom.Dd: true, atom.Table: true, atom.Thead: true, atom.Tbody: true, atom.Tfoot: true,
+	atom.Tr: true, atom.Td: true, atom.Th: true, atom.Caption: true, atom.Figure: true, atom.Figcaption: true,
+	atom.Hr: true, atom.Address: true, atom.Center: true, atom.Details: true, atom.Summary: true,
+	atom.Form: true, atom.Fieldset: true,
+}
+
+// 从 HTML 中提取内容；pageURL 用于把相对链接补全为绝对地址
+func extractContent(rawHTML string, pageURL string, opts contentOptions) (string, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(rawHTML))
+	if err != nil {
+		return "", newError(codeInternal, "解析页面 HTML 失败", err)
+	}
+	base, _ := net_url.Parse(pageURL)
+	return extractDocument(doc, base, opts), nil
+}
+
+func extractDocument(doc *goquery.Document, base *net_url.URL, opts contentOptions) string {
+	body := doc.Find("body").First()
+	if body.Length() == 0 {
+		return ""
+	}
+	body.Find(removedTags).Remove()
+	body.Find("[hidden], [aria-hidden='true']").Remove()
+	body.Find("[style]").FilterFunction(func(_ int, s *goquery.Selection) bool {
+		return hiddenStyleRe.MatchString(s.AttrOr("style", ""))
+	}).Remove()
+
+	r := &markdownRenderer{base: base, text: opts.Format == formatText}
+	if opts.Extract != extractMain {
+		return r.render(body.Nodes)
+	}
+
+	removePageChrome(body)
+	return r.render(mainContent(body, opts.SkipCandidate))
+}
+
+// 去掉导航、侧栏、页脚等页面框架，以及 class、id 表明不是正文的元素
+func removePageChrome(body *goquery.Selection) {
+	body.Find("nav, aside, footer").Remove()
+	body.Find(chromeRoles).Remove()
+	body.Find("header").FilterFunction(func(_ int, s *goquery.Selection) bool {
+		// 文章内的 header 通常包含标题与作者
+		return s.ParentsFiltered("article, main").Length() == 0
+	}).Remove()
+	body.Find("*").FilterFunction(func(_ int, s *goquery.Selection) bool {
+		if s.Is("article, main, table, tbody, thead, tr, td, th, pre, code") || s.ParentsFiltered("table, pre, code").Length() > 0 {
+			return false
+		}
+		match := s.AttrOr("class", "") + " " + s.AttrOr("id", "")
+		return unlikelyCandidateRe.MatchString(match) && !maybeCandidateRe.MatchString(match)
+	}).Remove()
+}
+
+// 正文打分
+type contentScorer struct {
+	scores map[*html.Node]float64
+	order  []*html.Node // 按首次打分的顺序，使同分时结果稳定
+}
+
+func (c *contentScorer) add(n *html.Node, score float64) {
+	if n == nil || n.Type != html.ElementNode || n.DataAtom == atom.Html {
+		return
+	}
+	if _, ok := c.scores[n]; !ok {
+		c.scores[n] = initialScore(n)
+		c.order = append(c.order, n)
+	}
+	c.scores[n] += score
+}
+
+// 按标签与 class、id 给候选容器的初始分
+func initialScore(n *html.Node) float64 {
+	var score float64
+	switch n.DataAtom {
+	case atom.Div, atom.Article, atom.Main:
+		score = 5
+	case atom.Pre, atom.Td, atom.Blockquote:
+		score = 3
+	case atom.Address, atom.Ol, atom.Ul, atom.Dl, atom.Dd, atom.Dt, atom.Li, atom.Form:
+		score = -3
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+		score = -5
+	}
+	return score + classWeight(n)
+}
+
+func classWeight(n *html.Node) float64 {
+	var weight float64
+	for _, name := range []string{"class", "id"} {
+		value := attr(n, name)
+		if value == "" {
+			continue
+		}
+		if negativeRe.MatchString(value) {
+			weight -= 25
+		}
+		if positiveRe.MatchString(value) {
+			weight += 25
+		}
+	}
+	return weight
+}
+
+// 找出正文容器，返回它及其相关的兄弟块；找不到时返回 body
+func mainContent(body *goquery.Selection, skip func(string) bool) []*html.Node {
+	scorer := &contentScorer{scores: make(map[*html.Node]float64)}
+	body.Find("p, pre, td, blockquote, div, section, article").Each(func(_ int, s *goquery.Selection) {
+		n := s.Nodes[0]
+		text := s.Text()
+		// div 等容器只计算直接包含的文本，如以 <br> 分段的正文
+		ownText := s.Is("div, section, article")
+		if ownText {
+			text = directText(n)
+		}
+		text = strings.TrimSpace(text)
+		length := utf8.RuneCountInString(text)
+		if length < 25 {
+			return
+		}
+		score := 1 + float64(countCommas(text)) + math.Min(float64(length)/100, 3)
+
+		// 段落为父元素加分，直接包含文本的容器为自己加分；越远的祖先加得越少
+		ancestor := n.Parent
+		if ownText {
+			ancestor = n
+		}
+		for level := 0; ancestor != nil && level < 3; level++ {
+			divider := 1.0
+			if level > 0 {
+				divider = float64(level) * 2
+			}
+			scorer.add(ancestor, score/divider)
+			ancestor = ancestor.Parent
+		}
+	})
+
+	var top *html.Node
+	var topScore float64
+	final := make(map[*html.Node]float64, len(scorer.order))
+	for _, n := range scorer.order {
+		s := goquery.NewDocumentFromNode(n).Selection
+		score := scorer.scores[n] * (1 - linkDensity(s))
+		final[n] = score
+		if skip != nil && skip(s.Text()) {
+			continue
+		}
+		if top == nil || score > topScore {
+			top, topScore = n, score
+		}
+	}
+	if top == nil || top.DataAtom == atom.Body {
+		return body.Nodes
+	}
+
+	// 同一父元素下得分相近或像正文段落的兄弟块一并保留，如被广告隔开的几段正文
+	threshold := math.Max(10, topScore*0.2)
+	var nodes []*html.Node
+	for sib := top.Parent.FirstChild; sib != nil; sib = sib.NextSibling {
+		if sib.Type != html.ElementNode {
+			continue
+		}
+		keep := sib == top
+		if score, ok := final[sib]; ok && score >= threshold && (skip == nil || !skip(nodeText(sib))) {
+			keep = true
+		}
+		if !keep && sib.DataAtom == atom.P {
+			s := goquery.NewDocumentFromNode(sib).Selection
+			text := strings.TrimSpace(s.Text())
+			length := utf8.RuneCountInString(text)
+			density := linkDensity(s)
+			keep = (length > 80 && density < 0.25) ||
+				(length > 0 && density == 0 && strings.ContainsAny(text, ".。!！?？"))
+		}
+		if keep {
+			cleanContent(goquery.NewDocumentFromNode(sib).Selection)
+			nodes = append(nodes, sib)
+		}
+	}
+	return nodes
+}
+
+// 去掉正文容器中以链接为主的列表、减分的块等杂项
+func cleanContent(s *goquery.Selection) {
+	s.Find("div, section, ul, ol, table, form").FilterFunction(func(_ int, el *goquery.Selection) bool {
+		if el.Find("pre, img, h1, h2, h3, h4, h5, h6").Length() > 0 && el.Find("a").Length() == 0 {
+			return false
+		}
+		weight := classWeight(el.Nodes[0])
+		if weight < 0 {
+			return true
+		}
+		return weight < 25 && linkDensity(el) > 0.5
+	}).Remove()
+}
+
+// 链接文本占全部文本的比例
+func linkDensity(s *goquery.Selection) float64 {
+	length := utf8.RuneCountInString(strings.TrimSpace(s.Text()))
+	if length == 0 {
+		return 0
+	}
+	var linkLength int
+	s.Find("a").Each(func(_ int, a *goquery.Selection) {
+		linkLength += utf8.RuneCountInString(strings.TrimSpace(a.Text()))
+	})
+	return float64(linkLength) / float64(length)
+}
+
+// 元素直接包含的文本，不含子元素中的文本
+func directText(n *html.Node) string {
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.TextNode {
+			b.WriteString(c.Data)
+		}
+	}
+	return b.String()
+}
+
+func nodeText(n *html.Node) string {
+	return goquery.NewDocumentFromNode(n).Text()
+}
+
+func countCommas(text string) int {
+	n := 0
+	for _, r := range text {
+		switch r {
+		case ',', '，', '、', '。', '；':
+			n++
+		}
+	}
+	return n
+}
+
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// 将 DOM 渲染为 Markdown；text 为 true 时输出不带标记的纯文本
+type markdownRenderer struct {
+	base *net_url.URL
+	text bool
+}
+
+func (r *markdownRenderer) render(nodes []*html.Node) string {
+	var blocks []string
+	for _, n := range nodes {
+		if b := r.block(n); b != "" {
+			blocks = append(blocks, b)
+		}
+	}
+	return strings.TrimSpace(r.joinBlocks(blocks))
+}
+
+// 纯文本每段一行，Markdown 段落之间空一行
+func (r *markdownRenderer) joinBlocks(blocks []string) string {
+	if r.text {
+		return strings.Join(blocks, "\n")
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+// 渲染子节点：连续的行内节点合并为一段，块元素各自成段
+func (r *markdownRenderer) children(n *html.Node) string {
+	var blocks []string
+	var inline strings.Builder
+	flush := func() {
+		if text := cleanLines(inline.String()); text != "" {
+			blocks = append(blocks, text)
+		}
+		inline.Reset()
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && blockTags[c.DataAtom] {
+			flush()
+			if b := r.block(c); b != "" {
+				blocks = append(blocks, b)
+			}
+			continue
+		}
+		inline.WriteString(r.inline(c))
+	}
+	flush()
+	return r.joinBlocks(blocks)
+}
+
+func (r *markdownRenderer) block(n *html.Node) string {
+	if n.Type != html.ElementNode {
+		return cleanLines(r.inline(n))
+	}
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		text := strings.Join(strings.Fields(r.inlineChildren(n)), " ")
+		if text == "" || r.text {
+			return text
+		}
+		level := int(n.Data[1] - '0')
+		return strings.Repeat("#", level) + " " + text
+	case atom.Pre:
+		code := strings.Trim(nodeText(n), "\n")
+		if code == "" || r.text {
+			return code
+		}
+		lang := ""
+		for _, el := range []*html.Node{n, n.FirstChild} {
+			if el != nil && el.Type == html.ElementNode {
+				if m := codeLanguageRe.FindStringSubmatch(attr(el, "class")); m != nil {
+					lang = m[1]
+					break
+				}
+			}
+		}
+		fence := "```"
+		for strings.Contains(code, fence) {
+			fence += "`"
+		}
+		return fence + lang + "\n" + code + "\n" + fence
+	case atom.Blockquote:
+		inner := r.children(n)
+		if inner == "" || r.text {
+			return inner
+		}
+		lines := strings.Split(inner, "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight("> "+line, " ")
+		}
+		return strings.Join(lines, "\n")
+	case atom.Ul, atom.Ol:
+		return r.list(n)
+	case atom.Table:
+		return r.table(n)
+	case atom.Hr:
+		if r.text {
+			return ""
+		}
+		return "---"
+	}
+	return r.children(n)
+}
+
+// 渲染列表，嵌套列表缩进到列表标记之后
+func (r *markdownRenderer) list(n *html.Node) string {
+	ordered := n.DataAtom == atom.Ol
+	index := 1
+	if start, err := strconv.Atoi(attr(n, "start")); err == nil && ordered {
+		index = start
+	}
+	var items []string
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode {
+			continue
+		}
+		marker := "- "
+		if ordered {
+			marker = strconv.Itoa(index) + ". "
+			index++
+		}
+		// 列表项内的段落与嵌套列表紧凑排列
+		content := strings.ReplaceAll(strings.TrimSpace(r.children(li)), "\n\n", "\n")
+		if content == "" {
+			continue
+		}
+		indent := strings.Repeat(" ", len(marker))
+		lines := strings.Split(content, "\n")
+		for i := 1; i < len(lines); i++ {
+			if lines[i] != "" {
+				lines[i] = indent + lines[i]
+			}
+		}
+		items = append(items, marker+strings.Join(lines, "\n"))
+	}
+	return strings.Join(items, "\n")
+}
+
+// 渲染表格，展开合并的单元格；没有表头时第一行作为表头，纯文本以制表符分隔单元格
+func (r *markdownRenderer) table(n *html.Node) string {
+	g := parseTable(n, func(cell *html.Node) string {
+		return strings.Join(strings.Fields(r.inlineChildren(cell)), " ")
+	})
+	return g.markdown(r.text)
+}
+
+// 表格的行，不含嵌套表格中的行
+func tableRows(table *html.Node) []*html.Node {
+	var rows []*html.Node
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			switch c.DataAtom {
+			case atom.Tr:
+				rows = append(rows, c)
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				walk(c)
+			}
+		}
+	}
+	walk(table)
+	return rows
+}
+
+func (r *markdownRenderer) inlineChildren(n *html.Node) string {
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(r.inline(c))
+	}
+	return b.String()
+}
+
+// 渲染行内节点；行内元素中的块元素按行内内容处理
+func (r *markdownRenderer) inline(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return spaceRe.ReplaceAllString(strings.ReplaceAll(n.Data, "\n", " "), " ")
+	case html.ElementNode:
+	default:
+		return ""
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		return "\n"
+	case atom.Img:
+		src := r.resolve(attr(n, "src"))
+		if r.text || src == "" {
+			return ""
+		}
+		return "![" + attr(n, "alt") + "](" + src + ")"
+	}
+
+	inner := r.inlineChildren(n)
+	if blockTags[n.DataAtom] {
+		// 行内元素中的块元素前后换行
+		return "\n" + inner + "\n"
+	}
+	if r.text {
+		return inner
+	}
+	switch n.DataAtom {
+	case atom.A:
+		href := r.resolve(attr(n, "href"))
+		text := strings.TrimSpace(inner)
+		if text == "" || href == "" || strings.Contains(text, "\n") {
+			return inner
+		}
+		return surround(inner, "[", "]("+href+")")
+	case atom.Strong, atom.B:
+		return surround(inner, "**", "**")
+	case atom.Em, atom.I:
+		return surround(inner, "*", "*")
+	case atom.Del, atom.S, atom.Strike:
+		return surround(inner, "~~", "~~")
+	case atom.Code, atom.Kbd, atom.Samp:
+		return surround(inner, "`", "`")
+	}
+	return inner
+}
+
+// 用标记包住去掉首尾空白的文本，保留原有的首尾空白
+func surround(s, open, close string) string {
+	trimmed := strings.TrimSpace(s)
+	if trimmed == "" {
+		return s
+	}
+	start := strings.Index(s, trimmed)
+	return s[:start] + open + trimmed + close + s[start+len(trimmed):]
+}
+
+// 把页面中的链接补全为绝对地址，忽略 javascript: 等无法访问的链接
+func (r *markdownRenderer) resolve(link string) string {
+	link = strings.TrimSpace(link)
+	if link == "" || strings.HasPrefix(link, "#") {
+		return ""
+	}
+	u, err := net_url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	if r.base != nil {
+		u = r.base.ResolveReference(u)
+	}
+	switch u.Scheme {
+	case "http", "https", "mailto", "":
+		return u.String()
+	}
+	return ""
+}
+
+// 去掉每行首尾的空白与空行
+func cleanLines(s string) string {
+	lines := strings.Split(s, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if line = strings.TrimSpace(spaceRe.ReplaceAllString(line, " ")); line != "" {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/storage"
+	"github.com/chromedp/chromedp"
+)
+
+// cookie 文件的格式
+const (
+	cookieFormatNetscape = "netscape" // curl、wget 与浏览器扩展使用的 cookies.txt
+	cookieFormatJSON     = "json"     // 与 CDP、Playwright 及 EditThisCookie 等扩展兼容的 JSON 数组
+)
+
+// Netscape 格式中 HttpOnly cookie 所在行的前缀
+const httpOnlyPrefix = "#HttpOnly_"
+
+// 导出与列出的 cookie；字段名与 CDP 一致，使导出的 JSON 可被其他工具直接读取
+type cookieInfo struct {
+	Name     string  `json:"name"`
+	Value    string  `json:"value"`
+	Domain   string  `json:"domain"` // 以 . 开头时对子域名同样有效，否则只对该主机有效
+	Path     string  `json:"path"`
+	Expires  float64 `json:"expires,omitempty"` // 过期时间的 Unix 秒数，会话 cookie 为 0
+	HTTPOnly bool    `json:"httpOnly"`
+	Secure   bool    `json:"secure"`
+	SameSite string  `json:"sameSite,omitempty"`
+	Session  bool    `json:"session"`
+}
+
+// 导入的 JSON cookie，兼容 CDP 的 expires 与浏览器扩展的 expirationDate、hostOnly
+type cookieImport struct {
+	Name           string   `json:"name"`
+	Value          string   `json:"value"`
+	URL            string   `json:"url"`
+	Domain         string   `json:"domain"`
+	Path           string   `json:"path"`
+	Expires        *float64 `json:"expires"`
+	ExpirationDate *float64 `json:"expirationDate"`
+	HTTPOnly       bool     `json:"httpOnly"`
+	Secure         bool     `json:"secure"`
+	SameSite       string   `json:"sameSite"`
+	HostOnly       *bool    `json:"hostOnly"`
+	Session        bool     `json:"session"`
+}
+
+func toCookieInfo(c *network.Cookie) cookieInfo {
+	info := cookieInfo{
+		Name:     c.Name,
+		Value:    c.Value,
+		Domain:   c.Domain,
+		Path:     c.Path,
+		HTTPOnly: c.HTTPOnly,
+		Secure:   c.Secure,
+		SameSite: c.SameSite.String(),
+		Session:  c.Session,
+	}
+	if !c.Session && c.Expires > 0 {
+		info.Expires = c.Expires
+	}
+	return info
+}
+
+// 判断 cookie 是否属于 domain 或其子域名；domain 为空时全部匹配
+func cookieMatchesDomain(cookieDomain, domain string) bool {
+	domain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), ".")
+	if domain == "" {
+		return true
+	}
+	d := strings.TrimPrefix(strings.ToLower(cookieDomain), ".")
+	return d == domain || strings.HasSuffix(d, "."+domain)
+}
+
+// 按文件扩展名确定 cookie 文件的格式，.json 为 JSON，其余为 Netscape
+func cookieFormatForPath(path string) string {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return cookieFormatJSON
+	}
+	return cookieFormatNetscape
+}
+
+// 构造设置 cookie 的参数；hostOnly 为 true 时 cookie 只对 domain 这个主机有效
+func newCookieParam(name, value, domain, path string, hostOnly, secure, httpOnly bool, expires float64, sameSite string) (*network.CookieParam, error) {
+	host := strings.TrimPrefix(strings.TrimSpace(domain), ".")
+	if name == "" || host == "" {
+		return nil, fmt.Errorf("cookie 缺少 name 或 domain")
+	}
+	if path == "" {
+		path = "/"
+	}
+	p := &network.CookieParam{Name: name, Value: value, Path: path, Secure: secure, HTTPOnly: httpOnly}
+	if hostOnly {
+		// CDP 只有通过 URL 才能设置只对主机有效的 cookie
+		scheme := "http"
+		if secure {
+			scheme = "https"
+		}
+		p.URL = scheme + "://" + host + path
+	} else {
+		p.Domain = "." + host
+	}
+	setCookieExpiry(p, expires, sameSite)
+	return p, nil
+}
+
+// 设置过期时间（Unix 秒数，0 表示会话 cookie）与 SameSite
+func setCookieExpiry(p *network.CookieParam, expires float64, sameSite string) {
+	if expires > 0 {
+		sec, frac := math.Modf(expires)
+		t := cdp.TimeSinceEpoch(time.Unix(int64(sec), int64(frac*1e9)))
+		p.Expires = &t
+	}
+	switch strings.ToLower(sameSite) {
+	case "strict":
+		p.SameSite = network.CookieSameSiteStrict
+	case "lax":
+		p.SameSite = network.CookieSameSiteLax
+	case "none", "no_restriction":
+		p.SameSite = network.CookieSameSiteNone
+	}
+}
+
+// 解析 cookie 文件，format 为空时按内容判断
+func parseCookies(data []byte, format string) ([]*network.CookieParam, error) {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	if format == "" {
+		format = cookieFormatNetscape
+		if len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+			format = cookieFormatJSON
+		}
+	}
+	switch format {
+	case cookieFormatJSON:
+		return parseJSONCookies(trimmed)
+	case cookieFormatNetscape:
+		return parseNetscapeCookies(trimmed)
+	}
+	return nil, newError(codeInvalidArgument, fmt.Sprintf("cookie 文件格式须为 netscape 或 json: %s", format), nil)
+}
+
+// 解析 cookies.txt：每行为 domain、includeSubdomains、path、secure、expires、name、value，以制表符分隔
+func parseNetscapeCookies(data []byte) ([]*network.CookieParam, error) {
+	var params []*network.CookieParam
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := strings.HasPrefix(line, httpOnlyPrefix)
+		line = strings.TrimPrefix(line, httpOnlyPrefix)
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) == 6 {
+			// 值为空时部分工具省略最后一列
+			fields = append(fields, "")
+		}
+		if len(fields) != 7 {
+			return nil, newError(codeInvalidArgument, fmt.Sprintf("cookies.txt 第 %d 行应有 7 列，实际为 %d 列", lineNo, len(fields)), nil)
+		}
+		expires, err := strconv.ParseFloat(fields[4], 64)
+		if err != nil {
+			return nil, newError(codeInvalidArgument, fmt.Sprintf("cookies.txt 第 %d 行的过期时间不合法: %s", lineNo, fields[4]), nil)
+		}
+		includeSubdomains := strings.EqualFold(fields[1], "TRUE")
+		p, err := newCookieParam(fields[5], fields[6], fields[0], fields[2], !includeSubdomains,
+			strings.EqualFold(fields[3], "TRUE"), httpOnly, expires, "")
+		if err != nil {
+			return nil, newError(codeInvalidArgument, fmt.Sprintf("cookies.txt 第 %d 行不合法", lineNo), err)
+		}
+		params = append(params, p)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, newError(codeIOError, "读取 cookies.txt 失败", err)
+	}
+	return params, nil
+}
+
+// 解析 JSON cookie：数组，或 Playwright storageState 那样带有 cookies 数组的对象
+func parseJSONCookies(data []byte) ([]*network.CookieParam, error) {
+	var cookies []cookieImport
+	if len(data) > 0 && data[0] == '{' {
+		var state struct {
+			Cookies []cookieImport `json:"cookies"`
+		}
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, newError(codeInvalidArgument, "cookie 文件不是合法的 JSON", err)
+		}
+		cookies = state.Cookies
+	} else if err := json.Unmarshal(data, &cookies); err != nil {
+		return nil, newError(codeInvalidArgument, "cookie 文件不是合法的 JSON", err)
+	}
+
+	params := make([]*network.CookieParam, 0, len(cookies))
+	for i, c := range cookies {
+		var expires float64
+		switch {
+		case c.Session:
+		case c.Expires != nil:
+			expires = *c.Expires
+		case c.ExpirationDate != nil:
+			expires = *c.ExpirationDate
+		}
+		if c.Domain == "" && c.URL != "" {
+			// 只有 URL 时交给浏览器按 URL 确定域名与路径
+			p := &network.CookieParam{Name: c.Name, Value: c.Value, URL: c.URL, Path: c.Path, Secure: c.Secure, HTTPOnly: c.HTTPOnly}
+			setCookieExpiry(p, expires, c.SameSite)
+			params = append(params, p)
+			continue
+		}
+		hostOnly := !strings.HasPrefix(c.Domain, ".")
+		if c.HostOnly != nil {
+			hostOnly = *c.HostOnly
+		}
+		p, err := newCookieParam(c.Name, c.Value, c.Domain, c.Path, hostOnly, c.Secure, c.HTTPOnly, expires, c.SameSite)
+		if err != nil {
+			return nil, newError(codeInvalidArgument, fmt.Sprintf("第 %d 个 cookie 不合法", i+1), err)
+		}
+		params = append(params, p)
+	}
+	return params, nil
+}
+
+// 把 cookie 序列化为 format 格式
+func formatCookies(cookies []*network.Cookie, format string) ([]byte, error) {
+	if format == cookieFormatJSON {
+		infos := make([]cookieInfo, 0, len(cookies))
+		for _, c := range cookies {
+			infos = append(infos, toCookieInfo(c))
+		}
+		return json.MarshalIndent(infos, "", "  ")
+	}
+
+	var b strings.Builder
+	b.WriteString("# Netscape HTTP Cookie File\n")
+	for _, c := range cookies {
+		domain := c.Domain
+		if c.HTTPOnly {
+			domain = httpOnlyPrefix + domain
+		}
+		var expires int64
+		if !c.Session && c.Expires > 0 {
+			expires = int64(c.Expires)
+		}
+		fmt.Fprintf(&b, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", domain, netscapeBool(strings.HasPrefix(c.Domain, ".")),
+			c.Path, netscapeBool(c.Secure), expires, c.Name, c.Value)
+	}
+	return []byte(b.String()), nil
+}
+
+func netscapeBool(v bool) string {
+	if v {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+// 读取浏览器中属于 domain 的所有 cookie，domain 为空时返回全部
+func browserCookies(ctx context.Context, domain string) ([]*network.Cookie, error) {
+	var cookies []*network.Cookie
+	err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		cookies, err = storage.GetCookies().Do(ctx)
+		return err
+	}))
+	if err != nil {
+		return nil, wrapError(err, codeNavTimeout, "读取 cookie 失败")
+	}
+	matched := cookies[:0]
+	for _, c := range cookies {
+		if cookieMatchesDomain(c.Domain, domain) {
+			matched = append(matched, c)
+		}
+	}
+	return matched, nil
+}
+
+// 从 cookie 文件导入，返回导入的数量；format 为空时按内容判断
+func importCookiesFile(ctx context.Context, path, format string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, newError(codeIOError, fmt.Sprintf("无法读取 cookie 文件 %s", path), err)
+	}
+	params, err := parseCookies(data, format)
+	if err != nil {
+		return 0, err
+	}
+	if len(params) == 0 {
+		return 0, nil
+	}
+	err = chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		return storage.SetCookies(params).Do(ctx)
+	}))
+	if err != nil {
+		return 0, wrapError(err, codeNavTimeout, "导入 cookie 失败")
+	}
+	return len(params), nil
+}
+
+// 把属于 domain 的 cookie 导出到文件，返回导出的数量
+func exportCookiesFile(ctx context.Context, path, format, domain string) (int, error) {
+	cookies, err := browserCookies(ctx, domain)
+	if err != nil {
+		return 0, err
+	}
+	data, err := formatCookies(cookies, format)
+	if err != nil {
+		return 0, newError(codeInternal, "序列化 cookie 失败", err)
+	}
+	// cookie 中可能有登录凭据，只允许当前用户读写
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return 0, newError(codeIOError, fmt.Sprintf("无法写入 cookie 文件 %s", path), err)
+	}
+	return len(cookies), nil
+}
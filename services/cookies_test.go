@@ -0,0 +1,171 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+)
+
+// 便于比较的 CookieParam 摘要；Expires 为 Unix 秒数，会话 cookie 为 0
+type cookieSummary struct {
+	Name, Value, Domain, URL, Path string
+	Secure, HTTPOnly               bool
+	Expires                        int64
+	SameSite                       string
+}
+
+func summarizeCookies(params []*network.CookieParam) []cookieSummary {
+	summaries := make([]cookieSummary, 0, len(params))
+	for _, p := range params {
+		s := cookieSummary{
+			Name: p.Name, Value: p.Value, Domain: p.Domain, URL: p.URL, Path: p.Path,
+			Secure: p.Secure, HTTPOnly: p.HTTPOnly, SameSite: p.SameSite.String(),
+		}
+		if p.Expires != nil {
+			s.Expires = p.Expires.Time().Unix()
+		}
+		summaries = append(summaries, s)
+	}
+	return summaries
+}
+
+func TestParseCookies(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		format string
+		want   []cookieSummary
+	}{
+		{
+			name: "netscape",
+			data: "# Netscape HTTP Cookie File\n\n" +
+				".example.com\tTRUE\t/\tTRUE\t1900000000\tsid\tabc=1\n" +
+				"www.example.com\tFALSE\t/app\tFALSE\t0\tlang\tzh\r\n",
+			want: []cookieSummary{
+				{Name: "sid", Value: "abc=1", Domain: ".example.com", Path: "/", Secure: true, Expires: 1900000000},
+				{Name: "lang", Value: "zh", URL: "http://www.example.com/app", Path: "/app"},
+			},
+		},
+		{
+			name: "netscape HttpOnly prefix and empty value",
+			data: "#HttpOnly_.example.com\tTRUE\t/\tFALSE\t1900000000\ttoken\tx y\n" +
+				"#HttpOnly_example.com\tFALSE\t/\ttrue\t0\tflag\n",
+			want: []cookieSummary{
+				{Name: "token", Value: "x y", Domain: ".example.com", Path: "/", HTTPOnly: true, Expires: 1900000000},
+				{Name: "flag", URL: "https://example.com/", Path: "/", Secure: true, HTTPOnly: true},
+			},
+		},
+		{
+			name:   "netscape with explicit format",
+			data:   "example.com\tFALSE\t\tFALSE\t0\ta\tb\n",
+			format: cookieFormatNetscape,
+			want:   []cookieSummary{{Name: "a", Value: "b", URL: "http://example.com/", Path: "/"}},
+		},
+		{
+			name: "json array",
+			data: `[
+				{"name": "sid", "value": "1", "domain": ".example.com", "path": "/", "expires": 1900000000, "secure": true, "sameSite": "Lax"},
+				{"name": "lang", "value": "zh", "domain": "example.com", "expirationDate": 1900000000.5, "hostOnly": false, "sameSite": "no_restriction"},
+				{"name": "tmp", "value": "x", "domain": "example.com", "expires": 1900000000, "session": true, "httpOnly": true},
+				{"name": "u", "value": "v", "url": "https://example.org/a"}
+			]`,
+			want: []cookieSummary{
+				{Name: "sid", Value: "1", Domain: ".example.com", Path: "/", Secure: true, Expires: 1900000000, SameSite: "Lax"},
+				{Name: "lang", Value: "zh", Domain: ".example.com", Path: "/", Expires: 1900000000, SameSite: "None"},
+				{Name: "tmp", Value: "x", URL: "http://example.com/", Path: "/", HTTPOnly: true},
+				{Name: "u", Value: "v", URL: "https://example.org/a"},
+			},
+		},
+		{
+			name: "json storage state",
+			data: "\xef\xbb\xbf" + `{"cookies": [{"name": "sid", "value": "1", "domain": ".example.com", "path": "/"}], "origins": []}`,
+			want: []cookieSummary{{Name: "sid", Value: "1", Domain: ".example.com", Path: "/"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := parseCookies([]byte(tt.data), tt.format)
+			if err != nil {
+				t.Fatalf("parseCookies: %v", err)
+			}
+			if got := summarizeCookies(params); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCookiesInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		format string
+		want   string
+	}{
+		{"too few columns", "example.com\tFALSE\t/\tFALSE\t0\n", "", "第 1 行应有 7 列"},
+		{"spaces instead of tabs", "example.com FALSE / FALSE 0 a b\n", "", "应有 7 列"},
+		{"bad expiry", "example.com\tFALSE\t/\tFALSE\tnever\ta\tb\n", "", "过期时间不合法"},
+		{"missing domain", "\tFALSE\t/\tFALSE\t0\ta\tb\n", "", "不合法"},
+		{"bad json", "[{", "", "不是合法的 JSON"},
+		{"unknown format", "", "yaml", "netscape 或 json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseCookies([]byte(tt.data), tt.format)
+			if classifyError(err) != codeInvalidArgument || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want INVALID_ARGUMENT containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// 导出后再导入应得到等价的 cookie
+func TestFormatCookiesRoundTrip(t *testing.T) {
+	expires := float64(time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC).Unix())
+	cookies := []*network.Cookie{
+		{Name: "sid", Value: "abc", Domain: ".example.com", Path: "/", Expires: expires, Secure: true, HTTPOnly: true},
+		{Name: "lang", Value: "zh", Domain: "www.example.com", Path: "/app", Expires: -1, Session: true},
+		{Name: "empty", Value: "", Domain: ".example.org", Path: "/", Expires: expires},
+	}
+	want := []cookieSummary{
+		{Name: "sid", Value: "abc", Domain: ".example.com", Path: "/", Secure: true, HTTPOnly: true, Expires: int64(expires)},
+		{Name: "lang", Value: "zh", URL: "http://www.example.com/app", Path: "/app"},
+		{Name: "empty", Domain: ".example.org", Path: "/", Expires: int64(expires)},
+	}
+	for _, format := range []string{cookieFormatNetscape, cookieFormatJSON} {
+		t.Run(format, func(t *testing.T) {
+			data, err := formatCookies(cookies, format)
+			if err != nil {
+				t.Fatalf("formatCookies: %v", err)
+			}
+			if format == cookieFormatNetscape && !strings.HasPrefix(string(data), "# Netscape HTTP Cookie File\n") {
+				t.Errorf("missing Netscape header:\n%s", data)
+			}
+			params, err := parseCookies(data, "")
+			if err != nil {
+				t.Fatalf("parseCookies: %v\n%s", err, data)
+			}
+			if got := summarizeCookies(params); !reflect.DeepEqual(got, want) {
+				t.Errorf("got  %+v\nwant %+v\nfile:\n%s", got, want, data)
+			}
+		})
+	}
+}
+
+func TestCookieFormatForPath(t *testing.T) {
+	tests := map[string]string{
+		"cookies.txt":       cookieFormatNetscape,
+		"/tmp/state.JSON":   cookieFormatJSON,
+		"cookies":           cookieFormatNetscape,
+		"dir.json/cookies":  cookieFormatNetscape,
+		"exported.json.bak": cookieFormatNetscape,
+	}
+	for path, want := range tests {
+		if got := cookieFormatForPath(path); got != want {
+			t.Errorf("cookieFormatForPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
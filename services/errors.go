@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"strings"
+
+	"github.com/chromedp/cdproto/runtime"
+)
+
+// 跨 cgo 边界的错误码，调用方依赖其数值，只可追加不可修改
+type errorCode int
+
+const (
+	codeOK              errorCode = 0
+	codeInvalidArgument errorCode = 1
+	codeBrowserNotFound errorCode = 2
+	codeNavTimeout      errorCode = 3
+	codeSelectorTimeout errorCode = 4
+	codeBlockedByPolicy errorCode = 5
+	codeCancelled       errorCode = 6
+	codeNavFailed       errorCode = 7
+	codeScriptFailed    errorCode = 8
+	codeIOError         errorCode = 9
+	codeNotFound        errorCode = 10
+	codeUnsupported     errorCode = 11
+	codeStaleRef        errorCode = 12
+	codeInternal        errorCode = 99
+)
+
+var errorCodeNames = map[errorCode]string{
+	codeOK:              "OK",
+	codeInvalidArgument: "INVALID_ARGUMENT",
+	codeBrowserNotFound: "BROWSER_NOT_FOUND",
+	codeNavTimeout:      "NAV_TIMEOUT",
+	codeSelectorTimeout: "SELECTOR_TIMEOUT",
+	codeBlockedByPolicy: "BLOCKED_BY_POLICY",
+	codeCancelled:       "CANCELLED",
+	codeNavFailed:       "NAV_FAILED",
+	codeScriptFailed:    "SCRIPT_FAILED",
+	codeIOError:         "IO_ERROR",
+	codeNotFound:        "NOT_FOUND",
+	codeUnsupported:     "UNSUPPORTED",
+	codeStaleRef:        "STALE_REF",
+	codeInternal:        "INTERNAL",
+}
+
+func (c errorCode) String() string {
+	if name, ok := errorCodeNames[c]; ok {
+		return name
+	}
+	return "UNKNOWN"
+}
+
+// 是否值得调用方稍后重试
+func (c errorCode) retryable() bool {
+	switch c {
+	case codeNavTimeout, codeSelectorTimeout, codeNavFailed:
+		return true
+	}
+	return false
+}
+
+// 带错误码的错误
+type serviceError struct {
+	Code    errorCode
+	Message string
+	Err     error
+}
+
+func (e *serviceError) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+	if e.Message == "" {
+		return e.Err.Error()
+	}
+	return e.Message + ": " + e.Err.Error()
+}
+
+func (e *serviceError) Unwrap() error {
+	return e.Err
+}
+
+func newError(code errorCode, message string, err error) *serviceError {
+	return &serviceError{Code: code, Message: message, Err: err}
+}
+
+// 按阶段包装错误：超时归入 timeoutCode，其余按错误内容分类
+func wrapError(err error, timeoutCode errorCode, message string) error {
+	if err == nil {
+		return nil
+	}
+	var se *serviceError
+	if errors.As(err, &se) {
+		return err
+	}
+	code := classifyError(err)
+	if code == codeNavTimeout {
+		code = timeoutCode
+	}
+	return newError(code, message, err)
+}
+
+// 根据错误内容推断错误码
+func classifyError(err error) errorCode {
+	var se *serviceError
+	var exception *runtime.ExceptionDetails
+	switch {
+	case err == nil:
+		return codeOK
+	case errors.As(err, &se):
+		return se.Code
+	case errors.As(err, &exception):
+		return codeScriptFailed
+	case errors.Is(err, context.Canceled):
+		return codeCancelled
+	case errors.Is(err, context.DeadlineExceeded):
+		return codeNavTimeout
+	case errors.Is(err, fs.ErrNotExist):
+		// 找不到浏览器的情况已由 startBrowser 归类，其余为读写文件失败
+		return codeIOError
+	}
+
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "net::ERR_BLOCKED"):
+		return codeBlockedByPolicy
+	case strings.Contains(msg, "net::ERR_TIMED_OUT"):
+		return codeNavTimeout
+	case strings.Contains(msg, "net::ERR_"), strings.Contains(msg, "page load error"):
+		return codeNavFailed
+	}
+	return codeInternal
+}
+
+// 返回给调用方的错误信息
+type errorInfo struct {
+	Code      int    `json:"code"`
+	Name      string `json:"name"`
+	Message   string `json:"message"`
+	Retryable bool   `json:"retryable"`
+}
+
+func toErrorInfo(err error) *errorInfo {
+	if err == nil {
+		return nil
+	}
+	code := classifyError(err)
+	return &errorInfo{
+		Code:      int(code),
+		Name:      code.String(),
+		Message:   err.Error(),
+		Retryable: code.retryable(),
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+/*
+#include <stdlib.h>
+
+// 事件回调：json 为事件内容，仅在回调执行期间有效；user_data 原样传回
+typedef void (*servicor_event_cb)(const char* json, void* user_data);
+
+void servicor_invoke_event_cb(servicor_event_cb cb, const char* json, void* user_data);
+*/
+import "C"
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// 事件类型
+const (
+	eventPageLoaded     = "page_loaded"
+	eventChapterStarted = "chapter_started"
+	eventChapterSaved   = "chapter_saved"
+	eventRetry          = "retry"
+	eventFinished       = "finished"
+)
+
+// 推送给调用方的事件
+type event struct {
+	Type  string    `json:"type"`
+	JobID string    `json:"job_id,omitempty"`
+	Time  time.Time `json:"time"`
+	Data  any       `json:"data,omitempty"`
+}
+
+// 当前注册的回调
+var (
+	eventMu       sync.RWMutex
+	eventCallback C.servicor_event_cb
+	eventUserData unsafe.Pointer
+)
+
+// 导出事件回调注册：传入 NULL 取消注册
+//
+// 回调可能在任意线程上被并发调用，须自行保证线程安全且尽快返回；
+// json 字符串在回调返回后即被释放，调用方如需保留须自行复制。
+//
+//export SetEventCallback
+func SetEventCallback(cb C.servicor_event_cb, userData unsafe.Pointer) {
+	eventMu.Lock()
+	defer eventMu.Unlock()
+	eventCallback = cb
+	eventUserData = userData
+}
+
+type jobIDKey struct{}
+
+// 将任务 ID 附加到 ctx，此后发出的事件都会带上该 ID
+func withJobID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, jobIDKey{}, id)
+}
+
+// 向已注册的回调发送事件；未注册回调时为空操作
+func emitEvent(ctx context.Context, typ string, data any) {
+	eventMu.RLock()
+	cb, userData := eventCallback, eventUserData
+	eventMu.RUnlock()
+	if cb == nil {
+		return
+	}
+
+	ev := event{Type: typ, Time: time.Now(), Data: data}
+	if id, ok := ctx.Value(jobIDKey{}).(string); ok {
+		ev.JobID = id
+	}
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("事件编码失败: %v", err)
+		return
+	}
+
+	cPayload := C.CString(string(payload))
+	defer C.free(unsafe.Pointer(cPayload))
+	C.servicor_invoke_event_cb(cb, cPayload, userData)
+}
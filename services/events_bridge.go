@@ -0,0 +1,13 @@
+package main
+
+// cgo 不能直接调用 C 函数指针，须经由 C 侧的桥接函数。
+// 含 //export 的文件的前导注释中只能有声明，因此定义单独放在本文件中。
+
+/*
+typedef void (*servicor_event_cb)(const char* json, void* user_data);
+
+void servicor_invoke_event_cb(servicor_event_cb cb, const char* json, void* user_data) {
+	cb(json, user_data);
+}
+*/
+import "C"
@@ -0,0 +1,368 @@
+package main
+
+import "C"
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	net_url "net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// 字段的取值来源
+const (
+	sourceText = "text" // 元素的可见文本（默认）
+	sourceHTML = "html" // 元素的内部 HTML
+	sourceAttr = "attr" // 元素的属性，由 attribute 指定
+)
+
+// 字段的值类型
+const (
+	valueString  = "string" // 去掉首尾空白的字符串（默认）
+	valueNumber  = "number" // 取文本中的第一个数字，可带千位分隔符，如 "$1,299.00" 为 1299
+	valueInteger = "integer"
+	valueBoolean = "boolean" // 空、false、no、0、off、否 为 false，其余为 true
+	valueURL     = "url"     // 按页面地址转为绝对地址
+)
+
+var (
+	// 文本中的数字，可带千位分隔符
+	numberRe = regexp.MustCompile(`[-+]?(?:\d{1,3}(?:,\d{3})+|\d+)(?:\.\d+)?|[-+]?\.\d+`)
+	// 表示否定的文本
+	falseWords = map[string]bool{"": true, "false": true, "no": true, "0": true, "off": true, "否": true, "无": true}
+)
+
+// 抽取模式中的一个字段
+type extractField struct {
+	Name string `json:"name"`
+	// CSS 选择器，xpath= 前缀或 //、./ 开头按 XPath 处理；相对于所在的根元素或上级字段的元素查找，
+	// // 开头的 XPath 按 .// 处理，为空时取该元素本身
+	Selector  string `json:"selector"`
+	Source    string `json:"source"`    // text（默认）、html 或 attr
+	Attribute string `json:"attribute"` // 要读取的属性，设置时 source 默认为 attr
+	// 对取到的文本执行的正则表达式（RE2 语法）：有捕获组时取第一组，否则取整个匹配；不匹配时值为 null
+	Regex string `json:"regex"`
+	Type  string `json:"type"` // string（默认）、number、integer、boolean 或 url
+	// 取所有匹配的元素，返回数组；否则只取第一个，没有匹配时值为 null
+	Multiple bool `json:"multiple"`
+	// 嵌套字段：每个匹配的元素返回一个对象，此时不能设置 source、attribute、regex 与 type
+	Fields []*extractField `json:"fields"`
+
+	re *regexp.Regexp
+}
+
+// 抽取模式：对 root 匹配的每个元素按 fields 生成一条记录，root 为空时整个页面生成一条记录
+type extractSchema struct {
+	loadOptions
+	Root   string          `json:"root"`
+	Fields []*extractField `json:"fields"`
+}
+
+// 抽取结果
+type extractResult struct {
+	FinalURL string           `json:"final_url"`
+	Title    string           `json:"title"`
+	Count    int              `json:"count"`
+	Records  []map[string]any `json:"records"`
+}
+
+func parseExtractSchema(raw string) (*extractSchema, error) {
+	var schema extractSchema
+	if err := json.Unmarshal([]byte(raw), &schema); err != nil {
+		return nil, newError(codeInvalidArgument, "抽取模式不是合法的 JSON", err)
+	}
+	if err := schema.loadOptions.validate(); err != nil {
+		return nil, err
+	}
+	if err := validateFields(schema.Fields, "fields"); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// 检查字段并补全默认值，path 用于在错误信息中指出字段的位置
+func validateFields(fields []*extractField, path string) error {
+	if len(fields) == 0 {
+		return newError(codeInvalidArgument, fmt.Sprintf("%s 不能为空", path), nil)
+	}
+	names := make(map[string]bool)
+	for _, f := range fields {
+		if f == nil || strings.TrimSpace(f.Name) == "" {
+			return newError(codeInvalidArgument, fmt.Sprintf("%s 中有字段缺少 name", path), nil)
+		}
+		if names[f.Name] {
+			return newError(codeInvalidArgument, fmt.Sprintf("%s 中的字段重名: %s", path, f.Name), nil)
+		}
+		names[f.Name] = true
+		fieldPath := path + "." + f.Name
+
+		if len(f.Fields) > 0 {
+			if f.Source != "" || f.Attribute != "" || f.Regex != "" || f.Type != "" {
+				return newError(codeInvalidArgument, fmt.Sprintf("%s 有嵌套字段，不能设置 source、attribute、regex 或 type", fieldPath), nil)
+			}
+			if err := validateFields(f.Fields, fieldPath); err != nil {
+				return err
+			}
+			continue
+		}
+
+		f.Source = strings.ToLower(strings.TrimSpace(f.Source))
+		switch {
+		case f.Source == "" && f.Attribute != "":
+			f.Source = sourceAttr
+		case f.Source == "":
+			f.Source = sourceText
+		}
+		switch f.Source {
+		case sourceText, sourceHTML:
+		case sourceAttr:
+			if strings.TrimSpace(f.Attribute) == "" {
+				return newError(codeInvalidArgument, fmt.Sprintf("%s 的 source 为 attr 时须指定 attribute", fieldPath), nil)
+			}
+		default:
+			return newError(codeInvalidArgument, fmt.Sprintf("%s 的 source 须为 text、html 或 attr: %s", fieldPath, f.Source), nil)
+		}
+
+		f.Type = strings.ToLower(strings.TrimSpace(f.Type))
+		switch f.Type {
+		case "":
+			f.Type = valueString
+		case valueString, valueNumber, valueInteger, valueBoolean, valueURL:
+		default:
+			return newError(codeInvalidArgument,
+				fmt.Sprintf("%s 的 type 须为 string、number、integer、boolean 或 url: %s", fieldPath, f.Type), nil)
+		}
+
+		if f.Regex != "" {
+			re, err := regexp.Compile(f.Regex)
+			if err != nil {
+				return newError(codeInvalidArgument, fmt.Sprintf("%s 的 regex 不合法", fieldPath), err)
+			}
+			f.re = re
+		}
+	}
+	return nil
+}
+
+// 在页面中执行，参数为去掉后处理规则的抽取模式，返回每条记录中各字段的原始文本
+const extractSchemaScript = `(spec) => {
+	const isXPath = sel => sel.startsWith('xpath=') || sel.startsWith('//') || sel.startsWith('./');
+	const query = (context, sel) => {
+		if (!isXPath(sel)) {
+			return Array.from(context.querySelectorAll(sel));
+		}
+		let xpath = sel.replace(/^xpath=/, '');
+		if (context.nodeType !== Node.DOCUMENT_NODE && xpath.startsWith('//')) {
+			// 以 // 开头的 XPath 从文档根查找，在元素内查找时改为相对路径
+			xpath = '.' + xpath;
+		}
+		const result = document.evaluate(xpath, context, null, XPathResult.ORDERED_NODE_SNAPSHOT_TYPE, null);
+		const nodes = [];
+		for (let i = 0; i < result.snapshotLength; i++) {
+			nodes.push(result.snapshotItem(i));
+		}
+		return nodes;
+	};
+	const value = (node, field) => {
+		// XPath 可能选中属性或文本节点
+		if (node.nodeType !== Node.ELEMENT_NODE) {
+			return node.nodeValue !== null ? node.nodeValue : node.textContent;
+		}
+		switch (field.source) {
+		case 'html':
+			return node.innerHTML;
+		case 'attr':
+			return node.getAttribute(field.attribute);
+		}
+		return node.innerText !== undefined ? node.innerText : node.textContent;
+	};
+	const record = (context, fields) => {
+		const out = {};
+		for (const field of fields) {
+			let nodes = field.selector ? query(context, field.selector) : [context];
+			if (!field.multiple) {
+				nodes = nodes.slice(0, 1);
+			}
+			const values = nodes.map(node => field.fields ? record(node, field.fields) : value(node, field));
+			out[field.name] = field.multiple ? values : (values.length > 0 ? values[0] : null);
+		}
+		return out;
+	};
+	const roots = spec.root ? query(document, spec.root) : [document.documentElement];
+	return roots.map(root => record(root, spec.fields));
+}`
+
+// 在当前页面中执行抽取模式，按字段的 regex 与 type 处理后返回记录
+func runExtractSchema(ctx context.Context, schema *extractSchema) (*extractResult, error) {
+	spec, err := json.Marshal(map[string]any{"root": schema.Root, "fields": schema.Fields})
+	if err != nil {
+		return nil, newError(codeInternal, "序列化抽取模式失败", err)
+	}
+	result := &extractResult{}
+	var raw []map[string]any
+	err = chromedp.Run(ctx,
+		chromedp.Evaluate(fmt.Sprintf("(%s)(%s)", extractSchemaScript, spec), &raw),
+		chromedp.Location(&result.FinalURL),
+		chromedp.Title(&result.Title),
+	)
+	if err != nil {
+		return nil, wrapError(err, codeScriptFailed, "执行抽取模式失败")
+	}
+
+	base, _ := net_url.Parse(result.FinalURL)
+	result.Records = make([]map[string]any, 0, len(raw))
+	for _, r := range raw {
+		result.Records = append(result.Records, convertRecord(r, schema.Fields, base))
+	}
+	result.Count = len(result.Records)
+	return result, nil
+}
+
+func convertRecord(raw map[string]any, fields []*extractField, base *net_url.URL) map[string]any {
+	record := make(map[string]any, len(fields))
+	for _, f := range fields {
+		v := raw[f.Name]
+		if !f.Multiple {
+			record[f.Name] = f.convert(v, base)
+			continue
+		}
+		items, _ := v.([]any)
+		values := make([]any, 0, len(items))
+		for _, item := range items {
+			values = append(values, f.convert(item, base))
+		}
+		record[f.Name] = values
+	}
+	return record
+}
+
+// 把页面返回的单个值转为字段的类型，无法转换时为 nil
+func (f *extractField) convert(v any, base *net_url.URL) any {
+	if len(f.Fields) > 0 {
+		nested, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		return convertRecord(nested, f.Fields, base)
+	}
+
+	text, ok := v.(string)
+	if !ok {
+		// 元素不存在或属性不存在
+		if f.Type == valueBoolean {
+			return false
+		}
+		return nil
+	}
+	text = strings.TrimSpace(text)
+	if f.re != nil {
+		m := f.re.FindStringSubmatch(text)
+		if m == nil {
+			return nil
+		}
+		text = m[0]
+		if len(m) > 1 {
+			text = m[1]
+		}
+		text = strings.TrimSpace(text)
+	}
+
+	switch f.Type {
+	case valueNumber, valueInteger:
+		n, err := strconv.ParseFloat(strings.ReplaceAll(numberRe.FindString(text), ",", ""), 64)
+		if err != nil {
+			return nil
+		}
+		if f.Type == valueInteger {
+			return int64(math.Trunc(n))
+		}
+		return n
+	case valueBoolean:
+		return !falseWords[strings.ToLower(text)]
+	case valueURL:
+		if text == "" {
+			return nil
+		}
+		return resolveMetaURL(base, text)
+	}
+	return text
+}
+
+// 在新的浏览器中打开页面并执行抽取模式
+func runExtract(url string, schema *extractSchema) (*extractResult, error) {
+	ctxTimeout, cancelTimeout := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancelTimeout()
+	ctx, cancel := newBrowserContext(ctxTimeout, browserOptions{})
+	defer cancel()
+	if err := startBrowser(ctx); err != nil {
+		return nil, err
+	}
+
+	if _, err := navigateAndWait(ctx, url, schema.loadOptions); err != nil {
+		log.Printf("访问失败: %v", err)
+		return nil, err
+	}
+	return runExtractSchema(ctx, schema)
+}
+
+// 导出按抽取模式提取结构化数据的功能
+//
+// schemaJSON 为 {root, fields, wait_until, ...}，加载选项与 VisitWithOptions 相同；fields 中每个字段为
+// {name, selector, source, attribute, regex, type, multiple, fields}，规则见 extractField。
+// 返回结果信封 {ok, data, error}，data 为 {final_url, title, count, records}，调用方须以 FreeString 释放。
+//
+//export ExtractStructured
+func ExtractStructured(url *C.char, schemaJSON *C.char) *C.char {
+	goURL := C.GoString(url)
+	if strings.TrimSpace(goURL) == "" {
+		return toCResponse(nil, newError(codeInvalidArgument, "URL 为空", nil))
+	}
+	schema, err := parseExtractSchema(C.GoString(schemaJSON))
+	if err != nil {
+		return toCResponse(nil, err)
+	}
+	return toCResponse(runExtract(goURL, schema))
+}
+
+// 导出会话内的结构化数据提取功能
+//
+// url 为空时在会话当前的页面中执行，不重新加载页面。schemaJSON 与 ExtractStructured 相同。
+// 返回结果信封 {ok, data, error}，data 与 ExtractStructured 相同，调用方须以 FreeString 释放。
+//
+//export SessionExtract
+func SessionExtract(sessionID *C.char, url *C.char, schemaJSON *C.char) *C.char {
+	s, err := lookupSession(C.GoString(sessionID))
+	if err != nil {
+		return toCResponse(nil, err)
+	}
+	schema, err := parseExtractSchema(C.GoString(schemaJSON))
+	if err != nil {
+		return toCResponse(nil, err)
+	}
+	goURL := strings.TrimSpace(C.GoString(url))
+
+	var result *extractResult
+	err = s.run(func(ctx context.Context) error {
+		if goURL != "" {
+			s.frame = nil
+			if _, err := navigateAndWait(ctx, goURL, schema.loadOptions); err != nil {
+				return err
+			}
+		}
+		var err error
+		result, err = runExtractSchema(ctx, schema)
+		return err
+	})
+	if err != nil {
+		log.Printf("会话 %s 抽取失败: %v", s.id, err)
+	}
+	return toCResponse(result, err)
+}
@@ -0,0 +1,51 @@
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"log"
+	"unsafe"
+)
+
+// 释放由本库返回给调用方的字符串
+//
+// 所有返回 *C.char 的导出函数都通过 C.CString 在 C 堆上分配内存，
+// 调用方用完后必须调用本函数释放，不可直接使用 Rust 的分配器释放。
+//
+//export FreeString
+func FreeString(s *C.char) {
+	if s == nil {
+		return
+	}
+	C.free(unsafe.Pointer(s))
+}
+
+// 将任意值编码为 JSON 并复制到 C 堆上，由调用方通过 FreeString 释放
+func toCJSON(v any) *C.char {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("JSON 编码失败: %v", err)
+		data = []byte("null")
+	}
+	return C.CString(string(data))
+}
+
+// 所有 JSON 导出函数统一返回的结果信封
+type response struct {
+	OK    bool       `json:"ok"`
+	Data  any        `json:"data,omitempty"`
+	Error *errorInfo `json:"error,omitempty"`
+}
+
+// 将结果与错误打包为结果信封；出错时仍附带已取得的部分数据
+func toCResponse(data any, err error) *C.char {
+	return toCJSON(response{
+		OK:    err == nil,
+		Data:  data,
+		Error: toErrorInfo(err),
+	})
+}
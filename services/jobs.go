@@ -0,0 +1,309 @@
+package main
+
+import "C"
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 已结束的任务在内存中保留的时长，过期后在下次启动任务时清理
+const finishedJobRetention = time.Hour
+
+// 任务状态
+const (
+	jobRunning   = "running"
+	jobDone      = "done"
+	jobFailed    = "failed"
+	jobCancelled = "cancelled"
+)
+
+// 下载选项，由 DownloadStart 的 optionsJSON 传入
+type downloadOptions struct {
+	OutputDir    string `json:"output_dir"`     // 保存目录，默认为当前目录
+	TimeoutSecs  int    `json:"timeout_secs"`   // 整体超时，0 表示不限
+	MinDelaySecs int    `json:"min_delay_secs"` // 章节间随机延迟的下限
+	MaxDelaySecs int    `json:"max_delay_secs"` // 章节间随机延迟的上限
+	MaxChapters  int    `json:"max_chapters"`   // 最多下载的章节数，0 表示不限
+}
+
+func defaultDownloadOptions() downloadOptions {
+	return downloadOptions{MinDelaySecs: 5, MaxDelaySecs: 60}
+}
+
+func parseDownloadOptions(raw string) (downloadOptions, error) {
+	opts := defaultDownloadOptions()
+	if strings.TrimSpace(raw) != "" {
+		if err := json.Unmarshal([]byte(raw), &opts); err != nil {
+			return opts, newError(codeInvalidArgument, "下载选项不是合法的 JSON", err)
+		}
+	}
+	if opts.MinDelaySecs < 0 || opts.MaxDelaySecs < opts.MinDelaySecs {
+		return opts, newError(codeInvalidArgument,
+			fmt.Sprintf("延迟范围无效: %d-%d 秒", opts.MinDelaySecs, opts.MaxDelaySecs), nil)
+	}
+	return opts, nil
+}
+
+// 章节间的随机延迟，避免请求过快
+func (o downloadOptions) delay() time.Duration {
+	return time.Duration(o.MinDelaySecs+rand.Intn(o.MaxDelaySecs-o.MinDelaySecs+1)) * time.Second
+}
+
+// 可被 ctx 打断的 Sleep
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// 下载进度，由 downloadNovel 更新、JobStatus 读取；nil 时所有方法均为空操作
+type downloadProgress struct {
+	mu            sync.Mutex
+	file          string
+	chaptersDone  int
+	chaptersTotal int
+	currentURL    string
+	bytesWritten  int64
+	errors        []string
+}
+
+func (p *downloadProgress) setFile(name string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.file = name
+}
+
+func (p *downloadProgress) setTotal(total int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.chaptersTotal = total
+}
+
+func (p *downloadProgress) setCurrent(url string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.currentURL = url
+}
+
+// 记录写入的字节数；newChapter 为 false 表示同一章节的分页
+func (p *downloadProgress) addWritten(n int, newChapter bool) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.bytesWritten += int64(n)
+	if newChapter {
+		p.chaptersDone++
+	}
+}
+
+func (p *downloadProgress) addError(msg string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.errors = append(p.errors, msg)
+}
+
+// 后台任务
+type job struct {
+	id         string
+	kind       string
+	url        string
+	cancel     context.CancelFunc
+	progress   *downloadProgress
+	startedAt  time.Time
+	mu         sync.Mutex
+	state      string
+	err        error
+	finishedAt time.Time
+}
+
+// 返回给调用方的任务状态
+type jobStatus struct {
+	ID            string     `json:"id"`
+	Kind          string     `json:"kind"`
+	State         string     `json:"state"`
+	URL           string     `json:"url"`
+	File          string     `json:"file,omitempty"`
+	ChaptersDone  int        `json:"chapters_done"`
+	ChaptersTotal int        `json:"chapters_total"`
+	CurrentURL    string     `json:"current_url"`
+	BytesWritten  int64      `json:"bytes_written"`
+	Errors        []string   `json:"errors"`
+	Error         *errorInfo `json:"error,omitempty"`
+	StartedAt     time.Time  `json:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+}
+
+func (j *job) finish(err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.err = err
+	j.finishedAt = time.Now()
+	switch classifyError(err) {
+	case codeOK:
+		j.state = jobDone
+	case codeCancelled:
+		j.state = jobCancelled
+	default:
+		j.state = jobFailed
+	}
+}
+
+func (j *job) status() jobStatus {
+	j.mu.Lock()
+	status := jobStatus{
+		ID:        j.id,
+		Kind:      j.kind,
+		State:     j.state,
+		URL:       j.url,
+		Error:     toErrorInfo(j.err),
+		StartedAt: j.startedAt,
+	}
+	if !j.finishedAt.IsZero() {
+		finishedAt := j.finishedAt
+		status.FinishedAt = &finishedAt
+	}
+	j.mu.Unlock()
+
+	p := j.progress
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	status.File = p.file
+	status.ChaptersDone = p.chaptersDone
+	status.ChaptersTotal = p.chaptersTotal
+	status.CurrentURL = p.currentURL
+	status.BytesWritten = p.bytesWritten
+	status.Errors = append([]string{}, p.errors...)
+	return status
+}
+
+// 任务注册表
+var (
+	jobsMu sync.Mutex
+	jobs   = make(map[string]*job)
+	jobSeq atomic.Int64
+)
+
+// 清理过期的已结束任务，调用方须持有 jobsMu
+func pruneJobsLocked() {
+	for id, j := range jobs {
+		j.mu.Lock()
+		expired := !j.finishedAt.IsZero() && time.Since(j.finishedAt) > finishedJobRetention
+		j.mu.Unlock()
+		if expired {
+			delete(jobs, id)
+		}
+	}
+}
+
+func lookupJob(id string) (*job, error) {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	j, ok := jobs[id]
+	if !ok {
+		return nil, newError(codeNotFound, fmt.Sprintf("任务不存在: %s", id), nil)
+	}
+	return j, nil
+}
+
+// 在后台 goroutine 中启动下载任务
+func startDownloadJob(novelURL string, opts downloadOptions) *job {
+	ctx, cancel := context.WithCancel(context.Background())
+	id := fmt.Sprintf("job-%d", jobSeq.Add(1))
+	ctx = withJobID(ctx, id)
+	j := &job{
+		id:        id,
+		kind:      "download",
+		url:       novelURL,
+		cancel:    cancel,
+		progress:  &downloadProgress{},
+		startedAt: time.Now(),
+		state:     jobRunning,
+	}
+
+	jobsMu.Lock()
+	pruneJobsLocked()
+	jobs[j.id] = j
+	jobsMu.Unlock()
+
+	go func() {
+		defer cancel()
+		_, err := runDownload(ctx, novelURL, opts, j.progress)
+		if err != nil {
+			log.Printf("下载任务 %s 结束: %v", j.id, err)
+		}
+		j.finish(err)
+	}()
+	return j
+}
+
+// 导出异步下载功能：立即返回任务 ID，下载在后台进行
+//
+// 返回结果信封 {ok, data, error}，data 为 {job_id}，调用方须以 FreeString 释放。
+//
+//export DownloadStart
+func DownloadStart(novelURL *C.char, optionsJSON *C.char) *C.char {
+	goURL := C.GoString(novelURL)
+	if strings.TrimSpace(goURL) == "" {
+		return toCResponse(nil, newError(codeInvalidArgument, "小说 URL 为空", nil))
+	}
+	opts, err := parseDownloadOptions(C.GoString(optionsJSON))
+	if err != nil {
+		return toCResponse(nil, err)
+	}
+	j := startDownloadJob(goURL, opts)
+	return toCResponse(map[string]string{"job_id": j.id}, nil)
+}
+
+// 导出任务状态查询
+//
+// 返回结果信封 {ok, data, error}，data 为任务状态，调用方须以 FreeString 释放。
+//
+//export JobStatus
+func JobStatus(jobID *C.char) *C.char {
+	j, err := lookupJob(C.GoString(jobID))
+	if err != nil {
+		return toCResponse(nil, err)
+	}
+	return toCResponse(j.status(), nil)
+}
+
+// 导出任务取消：通过任务的 context 通知其尽快退出
+//
+// 返回结果信封 {ok, data, error}，data 为取消时的任务状态，调用方须以 FreeString 释放。
+//
+//export JobCancel
+func JobCancel(jobID *C.char) *C.char {
+	j, err := lookupJob(C.GoString(jobID))
+	if err != nil {
+		return toCResponse(nil, err)
+	}
+	j.cancel()
+	return toCResponse(j.status(), nil)
+}
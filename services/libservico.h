@@ -1,6 +1,6 @@
 /* Code generated by cmd/cgo; DO NOT EDIT. */
 
-/* package command-line-arguments */
+/* package servicor */
 
 
 #line 1 "cgo-builtin-export-prolog"
@@ -23,6 +23,30 @@ extern const char *_GoStringPtr(_GoString_ s);
 
 
 
+#line 3 "events.go"
+
+#include <stdlib.h>
+
+// 事件回调：json 为事件内容，仅在回调执行期间有效；user_data 原样传回
+typedef void (*servicor_event_cb)(const char* json, void* user_data);
+
+void servicor_invoke_event_cb(servicor_event_cb cb, const char* json, void* user_data);
+
+#line 1 "cgo-generated-wrapper"
+
+
+#line 3 "ffi.go"
+
+#include <stdlib.h>
+
+#line 1 "cgo-generated-wrapper"
+
+
+
+
+
+
+
 /* End of preamble from import "C" comments.  */
 
 
@@ -82,9 +106,30 @@ typedef struct { void *data; GoInt len; GoInt cap; } GoSlice;
 extern "C" {
 #endif
 
+extern char* BrowserExec(char* sessionID, char* commandLine);
+extern char* BrowserExecWithOptions(char* sessionID, char* commandLine, char* optionsJSON);
+extern char* Screenshot(char* url, char* optionsJSON);
+extern char* PrintPDF(char* url, char* optionsJSON);
+extern void SetEventCallback(servicor_event_cb cb, void* userData);
+extern char* ExtractStructured(char* url, char* schemaJSON);
+extern char* SessionExtract(char* sessionID, char* url, char* schemaJSON);
+extern void FreeString(char* s);
+extern char* DownloadStart(char* novelURL, char* optionsJSON);
+extern char* JobStatus(char* jobID);
+extern char* JobCancel(char* jobID);
 extern void Search(char* keyword);
+extern char* SearchJSON(char* keyword);
 extern void Visit(char* url);
+extern char* VisitJSON(char* url);
 extern void Download(char* novelURL);
+extern char* DownloadJSON(char* novelURL);
+extern char* SearchWithOptions(char* keyword, char* optionsJSON);
+extern char* SessionOpen(char* optionsJSON);
+extern char* SessionClose(char* sessionID);
+extern char* SessionVisit(char* sessionID, char* url);
+extern char* SessionVisitWithOptions(char* sessionID, char* url, char* optionsJSON);
+extern char* SessionSearch(char* sessionID, char* keyword, char* optionsJSON);
+extern char* VisitWithOptions(char* url, char* optionsJSON);
 
 #ifdef __cplusplus
 }
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	net_url "net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/chromedp/chromedp"
+)
+
+// 页面中的一个链接
+type pageLink struct {
+	Text       string `json:"text"`
+	URL        string `json:"url"` // 绝对地址
+	Rel        string `json:"rel,omitempty"`
+	SameOrigin bool   `json:"same_origin"`
+	// 是否位于导航栏、页眉页脚、侧栏等页面框架中
+	navigation bool
+}
+
+// 页面中的一个标题
+type outlineHeading struct {
+	Level int    `json:"level"` // 1 到 6
+	Text  string `json:"text"`
+	URL   string `json:"url,omitempty"` // 标题带 id 时指向该标题的锚点
+}
+
+// extract 为 links 时返回的页面结构：标题大纲与按位置分组的链接，同组内相同地址与文字的链接只保留一个
+type pageOutline struct {
+	Headings        []outlineHeading `json:"headings"`
+	ContentLinks    []pageLink       `json:"content_links"`
+	NavigationLinks []pageLink       `json:"navigation_links"`
+}
+
+// 在页面中执行，返回完整的 HTML，包含折叠、隐藏的链接
+const documentHTMLScript = `'<!DOCTYPE html>' + document.documentElement.outerHTML`
+
+// 导航、页眉页脚与侧栏；文章内的 header 单独判断
+var navigationSelector = "nav, aside, footer, " + chromeRoles
+
+// 从页面 HTML 中提取标题大纲与链接
+func extractOutline(pageHTML, pageURL string) (*pageOutline, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(pageHTML))
+	if err != nil {
+		return nil, newError(codeInternal, "解析页面 HTML 失败", err)
+	}
+	base, _ := net_url.Parse(pageURL)
+	return documentOutline(doc, base), nil
+}
+
+func documentOutline(doc *goquery.Document, base *net_url.URL) *pageOutline {
+	outline := &pageOutline{
+		Headings:        []outlineHeading{},
+		ContentLinks:    []pageLink{},
+		NavigationLinks: []pageLink{},
+	}
+	doc.Find("body h1, body h2, body h3, body h4, body h5, body h6").Each(func(_ int, s *goquery.Selection) {
+		text := strings.Join(strings.Fields(s.Text()), " ")
+		if text == "" {
+			return
+		}
+		heading := outlineHeading{Level: int(goquery.NodeName(s)[1] - '0'), Text: text}
+		if id := s.AttrOr("id", ""); id != "" && base != nil {
+			anchor := *base
+			anchor.Fragment = id
+			heading.URL = anchor.String()
+		}
+		outline.Headings = append(outline.Headings, heading)
+	})
+
+	seen := make(map[string]bool)
+	for _, link := range documentLinks(doc, base) {
+		key := link.URL + "\x00" + link.Text
+		if link.navigation {
+			key = "nav\x00" + key
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if link.navigation {
+			outline.NavigationLinks = append(outline.NavigationLinks, link)
+		} else {
+			outline.ContentLinks = append(outline.ContentLinks, link)
+		}
+	}
+	return outline
+}
+
+// 按文档顺序列出 body 中的链接，跳过 javascript: 与只指向本页锚点的链接；page 为页面地址
+func documentLinks(doc *goquery.Document, page *net_url.URL) []pageLink {
+	var links []pageLink
+	base := documentBase(doc, page)
+	doc.Find("body a[href], body area[href]").Each(func(_ int, s *goquery.Selection) {
+		href := strings.TrimSpace(s.AttrOr("href", ""))
+		if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+			return
+		}
+		u, err := net_url.Parse(href)
+		if err != nil {
+			return
+		}
+		if base != nil {
+			u = base.ResolveReference(u)
+		}
+		text := strings.Join(strings.Fields(s.Text()), " ")
+		if text == "" {
+			// 图片链接以 alt 或 title 作为文字
+			text = firstNonEmpty(s.AttrOr("aria-label", ""), s.AttrOr("title", ""), s.Find("img[alt]").First().AttrOr("alt", ""), s.AttrOr("alt", ""))
+		}
+		links = append(links, pageLink{
+			Text:       text,
+			URL:        u.String(),
+			Rel:        strings.Join(strings.Fields(s.AttrOr("rel", "")), " "),
+			SameOrigin: page != nil && sameOrigin(page, u),
+			navigation: isNavigationLink(s),
+		})
+	})
+	return links
+}
+
+// 页面的 <base href> 会改变相对链接的基准地址
+func documentBase(doc *goquery.Document, base *net_url.URL) *net_url.URL {
+	href, ok := doc.Find("head base[href]").First().Attr("href")
+	if !ok {
+		return base
+	}
+	u, err := net_url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return base
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	return u
+}
+
+func sameOrigin(a, b *net_url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(a.Host, b.Host)
+}
+
+// 判断链接是否位于页面框架中，规则与提取正文时去掉的页面框架一致
+func isNavigationLink(s *goquery.Selection) bool {
+	if s.Closest(navigationSelector).Length() > 0 {
+		return true
+	}
+	for p := s.Parent(); p.Length() > 0 && !p.Is("body"); p = p.Parent() {
+		if p.Is("article, main") {
+			return false
+		}
+		if p.Is("header") {
+			return true
+		}
+		match := p.AttrOr("class", "") + " " + p.AttrOr("id", "")
+		if unlikelyCandidateRe.MatchString(match) && !maybeCandidateRe.MatchString(match) {
+			return true
+		}
+	}
+	return false
+}
+
+// 读取浏览器当前页面中的所有链接，按文档顺序返回
+func browserLinks(ctx context.Context) ([]pageLink, error) {
+	var pageHTML, pageURL string
+	if err := chromedp.Evaluate(documentHTMLScript, &pageHTML).Do(ctx); err != nil {
+		return nil, err
+	}
+	if err := chromedp.Location(&pageURL).Do(ctx); err != nil {
+		return nil, err
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(pageHTML))
+	if err != nil {
+		return nil, newError(codeInternal, "解析页面 HTML 失败", err)
+	}
+	base, _ := net_url.Parse(pageURL)
+	return documentLinks(doc, base), nil
+}
@@ -6,9 +6,9 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"math/rand"
 	net_url "net/url"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -25,24 +25,67 @@ func main() {}
 //export Search
 func Search(keyword *C.char) {
 	goKeyword := C.GoString(keyword)
-	allocCtx, cancelAlloc := chromedp.NewExecAllocator(context.Background(),
-		chromedp.NoFirstRun,
-		chromedp.NoDefaultBrowserCheck,
-		chromedp.Headless,
-		chromedp.DisableGPU,
-	)
-	defer cancelAlloc()
-
-	ctx, cancel := chromedp.NewContext(allocCtx)
-	defer cancel()
+	opts, _ := parseSearchOptions("")
+	resp, err := runSearch(goKeyword, opts)
+	if err != nil {
+		log.Printf("搜索功能执行失败: %v", err)
+		return
+	}
 
-	ctxTimeout, cancelTimeout := context.WithTimeout(ctx, 60*time.Second)
-	defer cancelTimeout()
+	// 打印搜索结果的标题和链接
+	for _, result := range resp.Results {
+		fmt.Printf("Title: %s\nLink: %s\n\n", result.Title, result.URL)
+	}
+}
 
-	searchURL := fmt.Sprintf("https://www.baidu.com/s?ie=UTF-8&wd=%s", goKeyword)
-	if err := search(ctxTimeout, searchURL); err != nil {
+// 导出搜索功能（JSON 版本）
+//
+// 返回结果信封 {ok, data, error}，data 为 [{title, url, resolved_url, snippet, source, date, rank, engine}]，
+// 使用默认的引擎顺序，调用方须以 FreeString 释放。
+//
+//export SearchJSON
+func SearchJSON(keyword *C.char) *C.char {
+	goKeyword := C.GoString(keyword)
+	if strings.TrimSpace(goKeyword) == "" {
+		return toCResponse(nil, newError(codeInvalidArgument, "搜索关键词为空", nil))
+	}
+	opts, _ := parseSearchOptions("")
+	resp, err := runSearch(goKeyword, opts)
+	if err != nil {
 		log.Printf("搜索功能执行失败: %v", err)
 	}
+	return toCResponse(resp.Results, err)
+}
+
+// 启动浏览器并执行一次搜索
+func runSearch(keyword string, opts searchOptions) (*searchResponse, error) {
+	backend := opts.Backend
+	if backend == backendAuto && len(opts.browserEngines()) == 0 {
+		log.Printf("所选的搜索引擎都不支持浏览器后端，使用 HTTP 搜索后端")
+		backend = backendHTTP
+	}
+	if backend == backendAuto && !browserAvailable("") {
+		log.Printf("未找到浏览器，使用 HTTP 搜索后端")
+		backend = backendHTTP
+	}
+	if backend == backendHTTP {
+		return searchWithOptions(context.Background(), keyword, opts, backendHTTP)
+	}
+
+	ctx, cancel := newBrowserContext(context.Background(), browserOptions{})
+	defer cancel()
+
+	// 先启动浏览器，否则并行搜索时各标签页的 context 会各自启动一个浏览器
+	err := startBrowser(ctx)
+	resp := &searchResponse{Results: []searchResult{}}
+	if err == nil {
+		resp, err = searchWithOptions(ctx, keyword, opts, backendBrowser)
+	}
+	if err != nil && opts.Backend == backendAuto && classifyError(err) == codeBrowserNotFound {
+		log.Printf("浏览器启动失败，改用 HTTP 搜索后端: %v", err)
+		return searchWithOptions(context.Background(), keyword, opts, backendHTTP)
+	}
+	return resp, err
 }
 
 // 导出访问功能
@@ -50,23 +93,80 @@ func Search(keyword *C.char) {
 //export Visit
 func Visit(url *C.char) {
 	goURL := C.GoString(url)
-	allocCtx, cancelAlloc := chromedp.NewExecAllocator(context.Background(),
-		chromedp.NoFirstRun,
-		chromedp.NoDefaultBrowserCheck,
-		chromedp.Headless,
-		chromedp.DisableGPU,
-	)
-	defer cancelAlloc()
+	opts, _ := parseVisitOptions("")
+	result, err := runVisit(goURL, opts)
+	if err != nil {
+		log.Printf("访问功能执行失败: %v", err)
+		return
+	}
 
-	ctx, cancel := chromedp.NewContext(allocCtx)
-	defer cancel()
+	fmt.Println(result.Text)
+}
+
+// 导出访问功能（JSON 版本）
+//
+// 返回结果信封 {ok, data, error}，data 为
+// {final_url, title, text, status_code, content_type, load_ms, wait_timed_out, fetched_with, kind, metadata}，
+// kind 为 html、json、text、pdf 或 image，PDF 另有 pages，图片另有 image；metadata 为 HTML 页面的
+// 标题、作者、发布时间、规范链接、语言、OpenGraph、Twitter 与 JSON-LD；VisitWithOptions 的 extract 为 links 时
+// 另有 outline。页面需要浏览器而找不到浏览器时返回 HTTP 获取的内容，needs_browser 为需要浏览器的原因。
+// 调用方须以 FreeString 释放。
+//
+//export VisitJSON
+func VisitJSON(url *C.char) *C.char {
+	goURL := C.GoString(url)
+	if strings.TrimSpace(goURL) == "" {
+		return toCResponse(nil, newError(codeInvalidArgument, "URL 为空", nil))
+	}
+	opts, _ := parseVisitOptions("")
+	result, err := runVisit(goURL, opts)
+	if err != nil {
+		log.Printf("访问功能执行失败: %v", err)
+	}
+	return toCResponse(result, err)
+}
 
-	ctxTimeout, cancelTimeout := context.WithTimeout(ctx, 60*time.Second)
+// 访问一个页面：按 opts.Fetch 先用 HTTP 获取，需要时再启动浏览器
+func runVisit(url string, opts visitOptions) (*visitResult, error) {
+	ctxTimeout, cancelTimeout := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancelTimeout()
 
-	if err := visitURL(ctxTimeout, goURL); err != nil {
-		log.Printf("访问功能执行失败: %v", err)
+	// HTTP 获取成功但需要浏览器时保留其结果，找不到浏览器时返回
+	var httpResult *visitResult
+	if opts.Fetch != fetchBrowser {
+		result, needsBrowser, err := fetchPage(ctxTimeout, url, opts)
+		switch {
+		case opts.Fetch == fetchHTTP:
+			return result, err
+		case needsBrowser != "":
+			log.Printf("HTTP 获取的页面需要浏览器（%s），改用浏览器: %s", needsBrowser, url)
+			if err == nil && result != nil {
+				httpResult = result
+				httpResult.NeedsBrowser = needsBrowser
+			}
+		case err == nil:
+			return result, nil
+		case ctxTimeout.Err() != nil:
+			return result, err
+		case result != nil && result.ContentType != "" && !isHTMLType(result.ContentType):
+			// 非 HTML 的内容在浏览器中同样无法提取
+			return result, err
+		default:
+			log.Printf("HTTP 获取失败，改用浏览器: %v", err)
+		}
 	}
+
+	ctx, cancel := newBrowserContext(ctxTimeout, browserOptions{})
+	defer cancel()
+	if err := startBrowser(ctx); err != nil {
+		if httpResult != nil && classifyError(err) == codeBrowserNotFound {
+			log.Printf("未找到浏览器，返回 HTTP 获取的内容: %s", url)
+			return httpResult, nil
+		}
+		return nil, err
+	}
+
+	return visitURL(ctx, url, opts)
 }
 
 // 导出下载功能
@@ -74,110 +174,243 @@ func Visit(url *C.char) {
 //export Download
 func Download(novelURL *C.char) {
 	goURL := C.GoString(novelURL)
-	allocCtx, cancelAlloc := chromedp.NewExecAllocator(context.Background(),
-		chromedp.NoFirstRun,
-		chromedp.NoDefaultBrowserCheck,
-		chromedp.Headless,
-		chromedp.DisableGPU,
-	)
-	defer cancelAlloc()
+	if _, err := runDownload(context.Background(), goURL, defaultDownloadOptions(), nil); err != nil {
+		log.Printf("下载功能执行失败: %v", err)
+	}
+}
 
-	ctx, cancel := chromedp.NewContext(allocCtx)
+// 导出下载功能（JSON 版本）
+//
+// 返回结果信封 {ok, data, error}，data 为 {file}，调用方须以 FreeString 释放。
+//
+//export DownloadJSON
+func DownloadJSON(novelURL *C.char) *C.char {
+	goURL := C.GoString(novelURL)
+	if strings.TrimSpace(goURL) == "" {
+		return toCResponse(nil, newError(codeInvalidArgument, "小说 URL 为空", nil))
+	}
+	fileName, err := runDownload(context.Background(), goURL, defaultDownloadOptions(), nil)
+	if err != nil {
+		log.Printf("下载功能执行失败: %v", err)
+	}
+	var data any
+	if fileName != "" {
+		data = map[string]string{"file": fileName}
+	}
+	return toCResponse(data, err)
+}
+
+// 启动浏览器并下载一部小说，返回保存的文件名；progress 可为 nil
+func runDownload(parent context.Context, novelURL string, opts downloadOptions, progress *downloadProgress) (string, error) {
+	ctx, cancel := newBrowserContext(parent, browserOptions{})
 	defer cancel()
 
-	// 下载可能耗时较长，不使用超时
-	if err := downloadNovel(ctx, goURL); err != nil {
-		log.Printf("下载功能执行失败: %v", err)
+	// 下载可能耗时较长，默认不使用超时
+	if opts.TimeoutSecs > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, time.Duration(opts.TimeoutSecs)*time.Second)
+		defer cancelTimeout()
 	}
+	var fileName string
+	err := startBrowser(ctx)
+	if err == nil {
+		fileName, err = downloadNovel(ctx, novelURL, opts, progress)
+	}
+	emitEvent(ctx, eventFinished, map[string]any{
+		"url":   novelURL,
+		"file":  fileName,
+		"error": toErrorInfo(err),
+	})
+	return fileName, err
 }
 
-// 搜索功能
-func search(ctx context.Context, searchURL string) error {
-	// 定义变量来存储搜索结果
-	var titles []string
-	var links []string
+// 搜索结果
+type searchResult struct {
+	Title       string `json:"title"`
+	URL         string `json:"url"`          // 结果页上的原始链接，可能是搜索引擎的跳转链接
+	ResolvedURL string `json:"resolved_url"` // 跟随跳转后规范化的目标地址
+	Snippet     string `json:"snippet"`
+	Source      string `json:"source,omitempty"` // 结果页上显示的站点名或域名
+	Date        string `json:"date,omitempty"`   // 结果页上显示的发布日期，格式因引擎而异
+	Rank        int    `json:"rank"`
+	Engine      string `json:"engine"`
+	// meta 模式下返回该结果的各引擎及其排名，以及合并得分
+	Engines []engineRank `json:"engines,omitempty"`
+	Score   float64      `json:"score,omitempty"`
+}
 
-	err := chromedp.Run(ctx,
-		chromedp.Navigate(searchURL),
-		chromedp.WaitVisible(`#content_left`, chromedp.ByQuery), // 等待搜索结果加载完成
-		chromedp.Tasks{
-			chromedp.ActionFunc(func(ctx context.Context) error {
-				// 获取所有匹配 h3.t a 的节点的 innerText 属性（标题）
-				var titleResults []string
-				err := chromedp.Evaluate(`Array.from(document.querySelectorAll('h3.t a')).map(a => a.innerText)`, &titleResults).Do(ctx)
-				if err != nil {
-					return err
-				}
-				titles = titleResults
+// 使用指定引擎执行搜索，按需翻页直到取得 opts.MaxResults 条结果
+func search(ctx context.Context, engine SearchEngine, q searchQuery, opts searchOptions) ([]searchResult, error) {
+	return collectPages(engine.Name(), q, opts, func(q searchQuery) ([]searchResult, error) {
+		return searchPage(ctx, engine, q, opts.engineTimeout())
+	})
+}
 
-				// 获取所有匹配 h3.t a 的节点的 href 属性（链接）
-				var linkResults []string
-				err = chromedp.Evaluate(`Array.from(document.querySelectorAll('h3.t a')).map(a => a.href)`, &linkResults).Do(ctx)
-				if err != nil {
-					return err
-				}
-				links = linkResults
+// 逐页调用 fetchPage 并按链接去重，直到取得 opts.MaxResults 条结果或没有新结果
+func collectPages(engine string, q searchQuery, opts searchOptions, fetchPage func(q searchQuery) ([]searchResult, error)) ([]searchResult, error) {
+	var results []searchResult
+	seen := make(map[string]bool)
 
-				return nil
-			}),
-		},
+	for q.Page = 0; q.Page < maxSearchPages && len(results) < opts.maxResults(); q.Page++ {
+		pageResults, err := fetchPage(q)
+		if err != nil {
+			if q.Page == 0 {
+				return nil, err
+			}
+			// 后续页失败时返回已取得的结果
+			log.Printf("搜索第 %d 页失败: %v", q.Page+1, err)
+			break
+		}
+
+		added := 0
+		for _, r := range pageResults {
+			if r.URL == "" || seen[r.URL] {
+				continue
+			}
+			seen[r.URL] = true
+			results = append(results, r)
+			added++
+		}
+		if added == 0 {
+			// 没有新结果，说明已到最后一页
+			break
+		}
+	}
+
+	if len(results) > opts.maxResults() {
+		results = results[:opts.maxResults()]
+	}
+	for i := range results {
+		results[i].Rank = i + 1
+		results[i].Engine = engine
+		if !opts.snippets() {
+			results[i].Snippet = ""
+		}
+	}
+	return results, nil
+}
+
+// 打开一页搜索结果并提取结果
+func searchPage(ctx context.Context, engine SearchEngine, q searchQuery, timeout time.Duration) ([]searchResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var results []searchResult
+	if err := chromedp.Run(ctx, chromedp.Navigate(engine.BuildURL(q))); err != nil {
+		log.Printf("搜索失败: %v", err)
+		return nil, wrapError(err, codeNavTimeout, "打开搜索页失败")
+	}
+
+	err := chromedp.Run(ctx,
+		chromedp.WaitVisible(engine.WaitSelector(), chromedp.ByQuery), // 等待搜索结果加载完成
+		chromedp.Evaluate(engine.ExtractScript(), &results),
 	)
 	if err != nil {
 		log.Printf("搜索失败: %v", err)
-		return err
+		return nil, wrapError(err, codeSelectorTimeout, "等待搜索结果失败")
 	}
+	return results, nil
+}
 
-	// 打印搜索结果的标题和链接
-	for i, title := range titles {
-		fmt.Printf("Title: %s\nLink: %s\n\n", title, links[i])
-	}
-	return nil
+// 访问结果
+type visitResult struct {
+	FinalURL    string `json:"final_url"`
+	Title       string `json:"title"`
+	Text        string `json:"text"`
+	StatusCode  int    `json:"status_code"`
+	ContentType string `json:"content_type"`
+	LoadMs      int64  `json:"load_ms"`
+	// 到达等待上限时页面仍未满足加载判定条件，内容可能不完整
+	WaitTimedOut bool `json:"wait_timed_out,omitempty"`
+	// 设置了长度预算时的分页信息：全文字符数、当前块序号（从 1 开始）、总块数与下一块的游标
+	TotalChars  int    `json:"total_chars,omitempty"`
+	Chunk       int    `json:"chunk,omitempty"`
+	TotalChunks int    `json:"total_chunks,omitempty"`
+	NextCursor  string `json:"next_cursor,omitempty"`
+	// 获取页面的方式：http 或 browser
+	FetchedWith string `json:"fetched_with"`
+	// 页面需要浏览器（如由 JS 渲染）但找不到浏览器时，需要浏览器的原因；此时内容来自 HTTP，可能不完整
+	NeedsBrowser string `json:"needs_browser,omitempty"`
+	// 内容类型：html、json、text、pdf 或 image；PDF 的页数与图片信息
+	Kind  string     `json:"kind"`
+	Pages int        `json:"pages,omitempty"`
+	Image *imageInfo `json:"image,omitempty"`
+	// HTML 页面的元数据，没有时省略
+	Metadata *pageMetadata `json:"metadata,omitempty"`
+	// extract 为 links 时的标题大纲与链接
+	Outline *pageOutline `json:"outline,omitempty"`
+	// 设置 tables 选项时单独提取的表格
+	Tables []extractedTable `json:"tables,omitempty"`
 }
 
 // 访问功能
-func visitURL(ctx context.Context, url string) error {
+func visitURL(ctx context.Context, url string, opts visitOptions) (*visitResult, error) {
 	// Variable to hold the result
-	var jsEnabled bool
+	result := &visitResult{FetchedWith: fetchBrowser}
+	start := time.Now()
+
+	// 导航并按加载策略等待，记录主文档的响应信息
+	load, err := navigateAndWait(ctx, url, opts.loadOptions)
+	if err != nil && strings.Contains(err.Error(), "net::ERR_ABORTED") {
+		// 浏览器把 PDF 等文件当作下载处理时导航会中止，探测到不是 HTML 时改用 HTTP 获取
+		if mediaType, probeErr := probeMediaType(ctx, url); probeErr == nil && mediaType != "" && !isHTMLType(mediaType) {
+			log.Printf("页面内容类型为 %s，改用 HTTP 获取: %s", mediaType, url)
+			result, _, err := fetchPage(ctx, url, opts)
+			return result, err
+		}
+	}
+	if err != nil {
+		log.Printf("访问失败: %v", err)
+		if load == nil {
+			return nil, err
+		}
+		return result, err
+	}
+	if load.Response != nil {
+		result.StatusCode = int(load.Response.Status)
+		result.ContentType = load.Response.MimeType
+	}
+	result.WaitTimedOut = load.TimedOut
+	emitEvent(ctx, eventPageLoaded, map[string]any{"url": url, "status_code": result.StatusCode})
 
-	// 直接访问URL并获取页面的纯文本内容
-	var pageText string
-	err := chromedp.Run(ctx,
-		chromedp.Navigate(url),
-		chromedp.WaitVisible(`body`, chromedp.ByQuery),     // 等待页面加载完成
-		chromedp.Sleep(15*time.Second),                     // 增加等待时间以确保所有内容加载完毕
-		chromedp.Evaluate("!!window.document", &jsEnabled), // Check if document object exists (JavaScript is enabled)
+	if load.Response != nil && load.Response.MimeType != "" && !isHTMLType(strings.ToLower(load.Response.MimeType)) {
+		// 非 HTML 的响应直接读取正文，不经过页面中的文本提取
+		if err := renderBrowserResponse(ctx, result, load); err != nil {
+			return result, err
+		}
+		result.LoadMs = time.Since(start).Milliseconds()
+		return paginateVisit(result, opts), nil
+	}
+	result.Kind = kindHTML
+
+	// 获取页面的纯文本内容
+	err = chromedp.Run(ctx,
+		chromedp.WaitReady(`body`, chromedp.ByQuery), // 等待 body 存在
 		chromedp.ActionFunc(func(ctx context.Context) error {
-			if !jsEnabled {
-				time.Sleep(15 * time.Second)
-			}
-			// 获取整个页面的文本内容，排除<script>和<style>标签以及特定的class
-			var textContent string
-			err := chromedp.Evaluate(`
-				function getTextContentWithoutScriptsAndStyles() {
-					const walker = document.createTreeWalker(document.body, NodeFilter.SHOW_TEXT, null, false);
-					let text = '';
-					while (walker.nextNode()) {
-						const node = walker.currentNode;
-						if (!node.parentElement.matches('script, style, .confirm-dialog') &&
-							window.getComputedStyle(node.parentElement).display !== 'none' &&
-							window.getComputedStyle(node.parentElement).visibility !== 'hidden') {
-							text += node.nodeValue.trim() + ' ';
-						}
-					}
-					return text.trim();
+			if opts.Extract == extractLinks {
+				// 包含折叠菜单等隐藏的链接
+				var pageHTML, pageURL string
+				if err := chromedp.Evaluate(documentHTMLScript, &pageHTML).Do(ctx); err != nil {
+					return err
 				}
-				getTextContentWithoutScriptsAndStyles()
-			`, &textContent).Do(ctx)
-			if err != nil {
+				if err := chromedp.Location(&pageURL).Do(ctx); err != nil {
+					return err
+				}
+				outline, err := extractOutline(pageHTML, pageURL)
+				result.Outline = outline
 				return err
 			}
-
-			if jsEnabled {
-				textContent = strings.TrimPrefix(textContent, "You need to enable JavaScript to run this app.")
+			// 取去掉不可见元素的 HTML，与 HTTP 获取的页面使用同样的提取逻辑
+			var pageHTML, pageURL string
+			if err := chromedp.Evaluate(visibleHTMLScript, &pageHTML).Do(ctx); err != nil {
+				return err
 			}
-
-			pageText = textContent
-			return nil
+			if err := chromedp.Location(&pageURL).Do(ctx); err != nil {
+				return err
+			}
+			text, err := extractContent(pageHTML, pageURL, contentOptions{Extract: opts.Extract, Format: opts.Format})
+			result.Text = text
+			return err
 		}),
 		chromedp.ActionFunc(func(ctx context.Context) error {
 			// 检查是否有JavaScript禁用提示
@@ -191,50 +424,73 @@ func visitURL(ctx context.Context, url string) error {
 			}
 			return nil
 		}),
+		chromedp.Location(&result.FinalURL),
+		chromedp.Title(&result.Title),
 	)
 	if err != nil {
 		log.Printf("访问失败: %v", err)
-		return err
+		return result, wrapError(err, codeSelectorTimeout, "读取页面内容失败")
 	}
 
-	fmt.Println(pageText)
-	return nil
+	if opts.Tables != tablesNone {
+		var pageHTML string
+		err := chromedp.Run(ctx, chromedp.Evaluate(visibleHTMLScript, &pageHTML))
+		if err == nil {
+			result.Tables, err = extractTables(pageHTML, opts.Tables)
+		}
+		if err != nil {
+			return result, wrapError(err, codeScriptFailed, "提取表格失败")
+		}
+	}
+
+	// 元数据只是附加信息，获取失败时不影响返回正文
+	var metaHTML string
+	if err := chromedp.Run(ctx, chromedp.Evaluate(metadataHTMLScript, &metaHTML)); err != nil {
+		log.Printf("获取页面元数据失败: %v", err)
+	} else if result.Metadata, err = extractMetadata(metaHTML, result.FinalURL); err != nil {
+		log.Printf("解析页面元数据失败: %v", err)
+	}
+
+	result.LoadMs = time.Since(start).Milliseconds()
+	return paginateVisit(result, opts), nil
 }
 
-// 下载小说功能
-func downloadNovel(ctx context.Context, novelURL string) error {
+// 下载小说功能，返回保存的文件名
+func downloadNovel(ctx context.Context, novelURL string, opts downloadOptions, progress *downloadProgress) (string, error) {
 	log.Printf("开始下载小说: %s\n", novelURL)
 
 	// 先访问小说目录页
 	err := chromedp.Run(ctx, chromedp.Navigate(novelURL))
 	if err != nil {
 		log.Printf("导航到小说页面失败: %v", err)
-		return err
+		return "", wrapError(err, codeNavTimeout, "导航到小说页面失败")
 	}
 
 	// 等待页面加载完成
 	err = chromedp.Run(ctx, chromedp.WaitVisible(`body`, chromedp.ByQuery))
 	if err != nil {
 		log.Printf("等待页面加载失败: %v", err)
-		return err
+		return "", wrapError(err, codeSelectorTimeout, "等待页面加载失败")
 	}
+	emitEvent(ctx, eventPageLoaded, map[string]any{"url": novelURL})
 
 	// 获取页面标题作为文件名
 	var pageTitle string
 	err = chromedp.Run(ctx, chromedp.Title(&pageTitle))
 	if err != nil {
 		log.Printf("获取页面标题失败: %v", err)
-		return err
+		return "", wrapError(err, codeNavTimeout, "获取页面标题失败")
 	}
 
 	// 清理标题，使其适合作为文件名
-	fileName := cleanFileName(pageTitle) + ".txt"
+	fileName := filepath.Join(opts.OutputDir, cleanFileName(pageTitle)+".txt")
+	progress.setFile(fileName)
 
 	// 创建文件
 	file, err := os.Create(fileName)
 	if err != nil {
 		log.Printf("无法创建文件: %v", err)
-		return err
+		return "", newError(codeIOError, "无法创建文件", err)
 	}
 	defer file.Close()
 
@@ -248,40 +504,19 @@ func downloadNovel(ctx context.Context, novelURL string) error {
 
 	err = chromedp.Run(ctx,
 		chromedp.ActionFunc(func(ctx context.Context) error {
-			var allLinks []struct {
-				Href string `json:"href"`
-				Text string `json:"text"`
-			}
-
-			erro := chromedp.Evaluate(
-				`Array.from(document.querySelectorAll('a')).map(a => ({href: a.href, text: a.textContent.trim()}))`,
-				&allLinks).Do(ctx)
+			allLinks, erro := browserLinks(ctx)
 			if erro != nil {
 				return erro
 			}
 
-			// 收集所有章节链接
+			// 收集所有章节链接，链接已解析为绝对地址
 			for _, link := range allLinks {
 				if chapterRegex.MatchString(link.Text) {
-					// 确保链接是绝对路径
-					absoluteURL, errn := net_url.Parse(link.Href)
-					if errn != nil {
-						continue
-					}
-
-					// 如果是相对路径，则基于当前URL解析
-					baseURL, errn := net_url.Parse(novelURL)
-					if errn != nil {
-						continue
-					}
-
-					absoluteChapterURL := baseURL.ResolveReference(absoluteURL).String()
-
 					chapterList = append(chapterList, struct {
 						Href string `json:"href"`
 						Text string `json:"text"`
 					}{
-						Href: absoluteChapterURL,
+						Href: link.URL,
 						Text: link.Text,
 					})
 				}
@@ -301,6 +536,7 @@ func downloadNovel(ctx context.Context, novelURL string) error {
 		totalChapterCount = -1 // 无法获取章节总数时设为-1
 	} else {
 		log.Printf("从目录页找到 %d 个章节", totalChapterCount)
+		progress.setTotal(totalChapterCount)
 	}
 
 	// 查找第1章的链接
@@ -403,14 +639,7 @@ func downloadNovel(ctx context.Context, novelURL string) error {
 	if firstChapterURL == "" {
 		err = chromedp.Run(ctx,
 			chromedp.ActionFunc(func(ctx context.Context) error {
-				var allLinks []struct {
-					Href string `json:"href"`
-					Text string `json:"text"`
-				}
-
-				erro := chromedp.Evaluate(
-					`Array.from(document.querySelectorAll('a')).map(a => ({href: a.href, text: a.textContent.trim()}))`,
-					&allLinks).Do(ctx)
+				allLinks, erro := browserLinks(ctx)
 				if erro != nil {
 					return erro
 				}
@@ -418,19 +647,7 @@ func downloadNovel(ctx context.Context, novelURL string) error {
 				// 查找第一个符合章节格式的链接
 				for _, link := range allLinks {
 					if chapterRegex.MatchString(link.Text) {
-						// 确保链接是绝对路径
-						aboluteURL, errn := net_url.Parse(link.Href)
-						if errn != nil {
-							continue
-						}
-
-						// 如果是相对路径，则基于当前URL解析
-						baseURL, errn := net_url.Parse(novelURL)
-						if errn != nil {
-							continue
-						}
-
-						firstChapterURL = baseURL.ResolveReference(aboluteURL).String()
+						firstChapterURL = link.URL
 						firstChapterTitle = link.Text
 						break
 					}
@@ -442,9 +659,9 @@ func downloadNovel(ctx context.Context, novelURL string) error {
 	}
 
 	if firstChapterURL == "" {
-		err := fmt.Errorf("无法找到任何章节链接")
+		err := newError(codeNotFound, "无法找到任何章节链接", nil)
 		log.Printf("%v", err)
-		return err
+		return fileName, err
 	}
 
 	fmt.Printf("找到第1章: %s\n", firstChapterTitle)
@@ -463,8 +680,14 @@ func downloadNovel(ctx context.Context, novelURL string) error {
 			break
 		}
 
+		// 任务被取消或整体超时，尽快退出
+		if err := ctx.Err(); err != nil {
+			return fileName, wrapError(err, codeNavTimeout, "下载已中止")
+		}
+
 		// 标记此URL为已访问
 		visitedURLs[currentChapterURL] = true
+		progress.setCurrent(currentChapterURL)
 
 		// 在goto之前声明所有可能被跳过的变量
 		var currentChapterTitle string
@@ -472,6 +695,7 @@ func downloadNovel(ctx context.Context, novelURL string) error {
 		var extractedTitle string
 		var isSameChapter bool  // 标记当前页面是否是同一章节的分页
 		var nextLinkText string // 用于存储下一章链接的文本内容
+		var written int         // 本页写入文件的字节数
 
 		// 为当前章节创建独立的超时上下文（5分钟）
 		chapterCtx, chapterCancel := context.WithTimeout(ctx, 300*time.Second)
@@ -488,7 +712,15 @@ func downloadNovel(ctx context.Context, novelURL string) error {
 					// 指数退避策略：第一次等待10秒，第二次20秒，第三次40秒
 					waitTime := time.Duration(10*(1<<retry)) * time.Second
 					log.Printf("等待%v后重试...", waitTime)
-					time.Sleep(waitTime)
+					emitEvent(ctx, eventRetry, map[string]any{
+						"url":     currentChapterURL,
+						"attempt": retry + 1,
+						"wait_ms": waitTime.Milliseconds(),
+						"error":   err.Error(),
+					})
+					if err := sleepContext(ctx, waitTime); err != nil {
+						return fileName, wrapError(err, codeNavTimeout, "下载已中止")
+					}
 					continue
 				}
 				// 最后一次重试也失败，才跳转到下一章
@@ -501,7 +733,15 @@ func downloadNovel(ctx context.Context, novelURL string) error {
 			if err != nil {
 				log.Printf("等待章节加载失败: %v", err)
 				if retry < maxRetries-1 {
-					time.Sleep(10 * time.Second)
+					emitEvent(ctx, eventRetry, map[string]any{
+						"url":     currentChapterURL,
+						"attempt": retry + 1,
+						"wait_ms": (10 * time.Second).Milliseconds(),
+						"error":   err.Error(),
+					})
+					if err := sleepContext(ctx, 10*time.Second); err != nil {
+						return fileName, wrapError(err, codeNavTimeout, "下载已中止")
+					}
 					continue
 				}
 				goto NextChapter
@@ -509,6 +749,7 @@ func downloadNovel(ctx context.Context, novelURL string) error {
 
 			// 导航和加载都成功
 			navigationSuccess = true
+			emitEvent(ctx, eventPageLoaded, map[string]any{"url": currentChapterURL})
 			break
 		}
 
@@ -518,6 +759,7 @@ func downloadNovel(ctx context.Context, novelURL string) error {
 			// 在文件中记录错误信息
 			errorMsg := fmt.Sprintf("【错误】无法访问章节: %s (URL: %s)\n\n", currentChapterTitle, currentChapterURL)
 			file.WriteString(errorMsg)
+			progress.addError(strings.TrimSpace(errorMsg))
 
 			// 尝试使用目录页中的下一章链接
 			if len(chapterList) > 0 && currentChapterIndex < len(chapterList) {
@@ -529,9 +771,11 @@ func downloadNovel(ctx context.Context, novelURL string) error {
 				currentChapterIndex++
 
 				// 添加随机延迟
-				randomDelay := time.Duration(5+rand.Intn(56)) * time.Second
+				randomDelay := opts.delay()
 				fmt.Printf("等待 %v 后尝试下一章...\n", randomDelay)
-				time.Sleep(randomDelay)
+				if err := sleepContext(ctx, randomDelay); err != nil {
+					return fileName, wrapError(err, codeNavTimeout, "下载已中止")
+				}
 				continue
 			} else {
 				fmt.Println("无法获取下一章链接，下载完成")
@@ -601,140 +845,36 @@ func downloadNovel(ctx context.Context, novelURL string) error {
 		if isSameChapter {
 			formattedTitle := fmt.Sprintf("%s_第%d页", currentChapterBaseTitle, currentPageNum)
 			fmt.Printf("正在下载第 %d 章: %s\n", currentChapterIndex, formattedTitle)
+			emitEvent(ctx, eventChapterStarted, map[string]any{
+				"index": currentChapterIndex,
+				"title": formattedTitle,
+				"page":  currentPageNum,
+				"url":   currentChapterURL,
+			})
 		} else {
 			fmt.Printf("正在下载第 %d 章: %s\n", currentChapterIndex, currentChapterTitle)
+			emitEvent(ctx, eventChapterStarted, map[string]any{
+				"index": currentChapterIndex,
+				"title": currentChapterTitle,
+				"page":  1,
+				"url":   currentChapterURL,
+			})
 			// 更新基础标题
 			currentChapterBaseTitle = extractBaseChapterTitle(currentChapterTitle)
 			// 重置页码计数
 			currentPageNum = 1
 		}
 
-		// 获取章节内容 - 改进版本，尝试找到包含大量连续文本的容器
+		// 获取章节内容：在去掉不可见元素的页面中找出得分最高的正文容器
 		err = chromedp.Run(chapterCtx,
 			chromedp.ActionFunc(func(ctx context.Context) error {
-				var content string
-				erro := chromedp.Evaluate(`
-                    function getChapterContent() {
-                        // 尝试找到包含大量连续文本且包含多个段落的容器
-                        const elements = document.querySelectorAll('div, article, section, span, pre, li, blockquote, main');
-                        let bestCandidate = null;
-                        let maxTextLength = 0;
-
-                        for (const element of elements) {
-                            // 跳过隐藏元素和不需要的元素
-                            if (window.getComputedStyle(element).display === 'none' ||
-                                window.getComputedStyle(element).visibility === 'hidden' ||
-                                window.getComputedStyle(element).opacity === '0' ||
-                                element.matches('script, style, .confirm-dialog, nav, footer, header, aside')) {
-                                continue;
-                            }
-
-                            const text = element.textContent.trim();
-                            const textLength = text.length;
-
-                            //跳过：条件1：同一行内同时包含「作者：」「分类：」「更新：」「字数：」的容器
-                            if (text.includes('作者：') && text.includes('分类：') && text.includes('更新：') && text.includes('字数：')) {
-                                continue;
-                            }
-
-                            //跳过：条件2：同一行内同时包含｛「上一章」或「上一页」｝「目录」｛「下一章」或「下一页」｝的容器
-                            if ((text.includes('上一章')||text.includes('上一页')) && text.includes('目录') && (text.includes('下一章')||text.includes('下一页'))) {
-                                continue;
-                            }
-
-                            //跳过：条件3：包含「投推荐票」或「加入书签」的容器
-                            if (text.includes('投推荐票')||text.includes('加入书签')) {
-                                continue;
-                            }
-
-                            // 如果文本长度足够长，并且比当前最佳候选更长
-                            if (textLength > 300 && textLength > maxTextLength) {
-                                // 检查文本质量：连续文本比例和段落数量
-                                const lineBreakCount = (text.match(/\n/g) || []).length;
-                                const paragraphCount = lineBreakCount + 1; // 假设每行一个段落
-
-                                // 如果文本质量较好，更新最佳候选
-                                if (textLength / paragraphCount > 50) {
-                                    bestCandidate = element;
-                                    maxTextLength = textLength;
-                                }
-                            }
-                        }
-
-                        // 定义文末可能存在且须要被去除的无关内容
-                        const delTextsOfEnd = ['上一章', '上一页', '目录', '目 录', '下一章', '下一页', '点击下一页继续阅读', '小说网更新速度全网最快。'];
-
-                        // 如果找到了合适的容器，提取其文本内容并保留段落格式
-                        if (bestCandidate) {
-                            // 使用 TreeWalker 提取文本内容并保留段落格式
-                            const walker = document.createTreeWalker(bestCandidate, NodeFilter.SHOW_TEXT, null, false);
-                            let text = '';
-                            while (walker.nextNode()) {
-                                const node = walker.currentNode;
-                                if (!node.parentElement.matches('script, style, .confirm-dialog') &&
-                                    window.getComputedStyle(node.parentElement).display !== 'none' &&
-                                    window.getComputedStyle(node.parentElement).visibility !== 'hidden' &&
-                                    window.getComputedStyle(node.parentElement).opacity !== '0') {
-                                    text += node.nodeValue.trim() + '\n';
-                                }
-                            }
-                            // 清理多余的空白字符，但保留段落格式
-                            text = text.trim().replace(/[^\S\n]+/g, ' ');
-
-                            // 从正文末尾倒序检查并去除导航部分
-                            const lines = text.split('\n');
-                            let cleanedText = '';
-
-                            // 只检查最后的10行内容
-                            const startLine = Math.max(0, lines.length - 10);
-                            for (let i = lines.length - 1; i >= startLine; i--) {
-                                if (!delTextsOfEnd.some(navigationText => lines[i].trim().includes(navigationText))) {
-                                    cleanedText = lines[i] + (cleanedText ? '\n' + cleanedText : '');
-                                }
-                            }
-
-                            // 合并剩余的文本
-                            cleanedText = lines.slice(0, startLine).join('\n') + (cleanedText ? '\n' + cleanedText : '');
-
-                            return cleanedText.trim();
-                        }
-
-                        // 如果未有找到合适的容器，回退到原来的方法
-                        const walker = document.createTreeWalker(document.body, NodeFilter.SHOW_TEXT, null, false);
-                        let text = '';
-                        while (walker.nextNode()) {
-                            const node = walker.currentNode;
-                            if (!node.parentElement.matches('script, style, .confirm-dialog, nav, footer, header, aside') &&
-                                window.getComputedStyle(node.parentElement).display !== 'none' &&
-                                window.getComputedStyle(node.parentElement).visibility !== 'hidden' &&
-                                window.getComputedStyle(node.parentElement).opacity !== '0') {
-                                text += node.nodeValue.trim() + '\n';
-                            }
-                        }
-                        // 清理多余的空白字符，但保留段落格式
-                        text = text.trim().replace(/[^\S\n]+/g, ' ');
-
-                        // 从正文末尾倒序检查并去除导航部分
-                        const lines = text.split('\n');
-                        let cleanedText = '';
-
-                        // 只检查最后的10行内容
-                        const startLine = Math.max(0, lines.length - 10);
-                        for (let i = lines.length - 1; i >= startLine; i--) {
-                            if (!delTextsOfEnd.some(navigationText => lines[i].trim().includes(navigationText))) {
-                                cleanedText = lines[i] + (cleanedText ? '\n' + cleanedText : '');
-                            }
-                        }
-
-                        // 合并剩余的文本
-                        cleanedText = lines.slice(0, startLine).join('\n') + (cleanedText ? '\n' + cleanedText : '');
-
-                        return cleanedText.trim();
-                    }
-                    getChapterContent()
-                `, &content).Do(ctx)
-				if erro != nil {
-					return erro
+				var pageHTML string
+				if err := chromedp.Evaluate(visibleHTMLScript, &pageHTML).Do(ctx); err != nil {
+					return err
+				}
+				content, err := extractChapterContent(pageHTML, currentChapterURL)
+				if err != nil {
+					return err
 				}
 				chapterContent = content
 				return nil
@@ -746,6 +886,7 @@ func downloadNovel(ctx context.Context, novelURL string) error {
 			// 在文件中记录错误信息
 			errorMsg := fmt.Sprintf("【错误】获取章节内容失败: %s (URL: %s)\n\n", currentChapterTitle, currentChapterURL)
 			file.WriteString(errorMsg)
+			progress.addError(strings.TrimSpace(errorMsg))
 			// 尝试继续查找下一章
 			goto NextChapter
 		}
@@ -753,13 +894,22 @@ func downloadNovel(ctx context.Context, novelURL string) error {
 		// 写入文件
 		if !isSameChapter {
 			// 新章节，写入标题和内容
-			_, err = file.WriteString(fmt.Sprintf("%s\n\n%s\n\n", currentChapterTitle, chapterContent))
+			written, err = file.WriteString(fmt.Sprintf("%s\n\n%s\n\n", currentChapterTitle, chapterContent))
 		} else {
 			// 同一章节的分页，只写入内容，不写入标题
-			_, err = file.WriteString(fmt.Sprintf("%s\n\n", chapterContent))
+			written, err = file.WriteString(fmt.Sprintf("%s\n\n", chapterContent))
 		}
 		if err != nil {
 			log.Printf("写入章节内容失败: %v", err)
+			progress.addError(fmt.Sprintf("写入章节内容失败: %v", err))
+		} else {
+			progress.addWritten(written, !isSameChapter)
+			emitEvent(ctx, eventChapterSaved, map[string]any{
+				"index": currentChapterIndex,
+				"title": currentChapterTitle,
+				"bytes": written,
+				"url":   currentChapterURL,
+			})
 		}
 
 	NextChapter:
@@ -930,8 +1080,14 @@ func downloadNovel(ctx context.Context, novelURL string) error {
 			break
 		}
 
-		// 为了避免请求过快，添加5到60秒的随机延迟
-		randomDelay := time.Duration(5+rand.Intn(56)) * time.Second
+		// 检查是否已达到调用方限定的章节数
+		if opts.MaxChapters > 0 && currentChapterIndex >= opts.MaxChapters {
+			fmt.Println("已达到章节数上限，下载完成")
+			break
+		}
+
+		// 为了避免请求过快，添加随机延迟（默认5到60秒）
+		randomDelay := opts.delay()
 		// 先获取下一章链接文本并判断是否为同一章节
 		nextLinkText = ""
 		// 使用chapterCtx替代ctx，避免上下文超时
@@ -997,11 +1153,61 @@ func downloadNovel(ctx context.Context, novelURL string) error {
 		} else {
 			fmt.Printf("等待 %v 后下载下一章...\n", randomDelay)
 		}
-		time.Sleep(randomDelay)
+		if err := sleepContext(ctx, randomDelay); err != nil {
+			return fileName, wrapError(err, codeNavTimeout, "下载已中止")
+		}
 	}
 
 	fmt.Printf("小说下载完成，保存至: %s\n", fileName)
-	return nil
+	return fileName, nil
+}
+
+// 章节页中不能作为正文的容器：书籍信息栏、翻页导航与推荐按钮
+func isNovelBoilerplate(text string) bool {
+	//条件1：同时包含「作者：」「分类：」「更新：」「字数：」
+	if strings.Contains(text, "作者：") && strings.Contains(text, "分类：") && strings.Contains(text, "更新：") && strings.Contains(text, "字数：") {
+		return true
+	}
+	//条件2：同时包含｛「上一章」或「上一页」｝「目录」｛「下一章」或「下一页」｝
+	if (strings.Contains(text, "上一章") || strings.Contains(text, "上一页")) && strings.Contains(text, "目录") &&
+		(strings.Contains(text, "下一章") || strings.Contains(text, "下一页")) {
+		return true
+	}
+	//条件3：包含「投推荐票」或「加入书签」
+	return strings.Contains(text, "投推荐票") || strings.Contains(text, "加入书签")
+}
+
+// 文末可能存在且须要被去除的无关内容
+var chapterTrailingTexts = []string{"上一章", "上一页", "目录", "目 录", "下一章", "下一页", "点击下一页继续阅读", "小说网更新速度全网最快。"}
+
+// 提取章节正文，并去除末尾的翻页导航
+func extractChapterContent(pageHTML, pageURL string) (string, error) {
+	text, err := extractContent(pageHTML, pageURL, contentOptions{
+		Extract:       extractMain,
+		Format:        formatText,
+		SkipCandidate: isNovelBoilerplate,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// 只检查最后的10行内容
+	lines := strings.Split(text, "\n")
+	startLine := max(0, len(lines)-10)
+	kept := append([]string(nil), lines[:startLine]...)
+	for _, line := range lines[startLine:] {
+		isNav := false
+		for _, navigationText := range chapterTrailingTexts {
+			if strings.Contains(line, navigationText) {
+				isNav = true
+				break
+			}
+		}
+		if !isNav {
+			kept = append(kept, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(kept, "\n")), nil
 }
 
 // 提取章节的基础标题，去除可能的分页信息
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	net_url "net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// 页面元数据：常用字段取自 OpenGraph、Twitter、<meta> 与 JSON-LD 中最先出现的值
+type pageMetadata struct {
+	Title         string   `json:"title,omitempty"`
+	Description   string   `json:"description,omitempty"`
+	Author        string   `json:"author,omitempty"`
+	PublishedTime string   `json:"published_time,omitempty"`
+	ModifiedTime  string   `json:"modified_time,omitempty"`
+	SiteName      string   `json:"site_name,omitempty"`
+	Type          string   `json:"type,omitempty"` // og:type 或 JSON-LD 中主要对象的 @type
+	Image         string   `json:"image,omitempty"`
+	Keywords      []string `json:"keywords,omitempty"`
+	CanonicalURL  string   `json:"canonical_url,omitempty"`
+	Language      string   `json:"language,omitempty"`
+
+	// 原始标签，键为去掉 og: 或 twitter: 前缀的属性名，如 title、image:width
+	OpenGraph map[string]string `json:"open_graph,omitempty"`
+	Twitter   map[string]string `json:"twitter,omitempty"`
+	// 解析后的 JSON-LD 对象，@graph 中的对象已展开
+	JSONLD []jsonLDItem `json:"json_ld,omitempty"`
+}
+
+// 一个 schema.org 对象，如 Article、Product、Recipe、Event
+type jsonLDItem struct {
+	Type string         `json:"type"`
+	Data map[string]any `json:"data"`
+}
+
+// 只保留元数据所需部分的页面 HTML：<head> 与正文中的 JSON-LD 脚本，
+// 不可见的 script 元素会被 visibleHTMLScript 去掉，因此单独获取
+const metadataHTMLScript = `(() => {
+	const html = document.documentElement;
+	const scripts = Array.from(document.querySelectorAll('body script[type="application/ld+json"]'));
+	const lang = (html.getAttribute('lang') || '').replace(/"/g, '&quot;');
+	return '<!DOCTYPE html><html lang="' + lang + '">' +
+		(document.head ? document.head.outerHTML : '') +
+		'<body>' + scripts.map(s => s.outerHTML).join('') + '</body></html>';
+})()`
+
+// 从页面 HTML 中提取元数据，pageURL 用于把相对地址转为绝对地址
+func extractMetadata(pageHTML, pageURL string) (*pageMetadata, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(pageHTML))
+	if err != nil {
+		return nil, newError(codeInternal, "解析页面 HTML 失败", err)
+	}
+	base, _ := net_url.Parse(pageURL)
+	return documentMetadata(doc, base), nil
+}
+
+// 从已解析的文档中提取元数据；没有任何元数据时返回 nil
+func documentMetadata(doc *goquery.Document, base *net_url.URL) *pageMetadata {
+	m := &pageMetadata{
+		OpenGraph: make(map[string]string),
+		Twitter:   make(map[string]string),
+	}
+	named := make(map[string]string)
+
+	doc.Find("meta").Each(func(_ int, s *goquery.Selection) {
+		content := strings.TrimSpace(s.AttrOr("content", ""))
+		if content == "" {
+			return
+		}
+		// OpenGraph 规定用 property，但不少站点写成 name，两者都读取
+		key := strings.ToLower(strings.TrimSpace(s.AttrOr("property", "")))
+		if key == "" {
+			key = strings.ToLower(strings.TrimSpace(s.AttrOr("name", "")))
+		}
+		var target map[string]string
+		switch {
+		case strings.HasPrefix(key, "og:"):
+			target, key = m.OpenGraph, strings.TrimPrefix(key, "og:")
+		case strings.HasPrefix(key, "article:"):
+			// article:published_time 等属于 OpenGraph 的文章扩展
+			target = m.OpenGraph
+		case strings.HasPrefix(key, "twitter:"):
+			target, key = m.Twitter, strings.TrimPrefix(key, "twitter:")
+		case key != "":
+			target = named
+		default:
+			return
+		}
+		// 同名标签取第一个，og:image 等可重复的标签以第一个为主图
+		if _, ok := target[key]; !ok {
+			target[key] = content
+		}
+	})
+
+	m.JSONLD = documentJSONLD(doc)
+	var ld map[string]any
+	if item := primaryJSONLD(m.JSONLD); item != nil {
+		ld = item.Data
+		m.Type = item.Type
+	}
+
+	m.Title = firstNonEmpty(m.OpenGraph["title"], m.Twitter["title"], ldString(ld, "headline"), ldString(ld, "name"),
+		strings.TrimSpace(doc.Find("title").First().Text()))
+	m.Description = firstNonEmpty(m.OpenGraph["description"], m.Twitter["description"], named["description"], ldString(ld, "description"))
+	m.Author = firstNonEmpty(named["author"], m.OpenGraph["article:author"], ldString(ld, "author"), m.Twitter["creator"])
+	m.PublishedTime = firstNonEmpty(m.OpenGraph["article:published_time"], ldString(ld, "datePublished"),
+		named["date"], named["pubdate"], named["publishdate"], named["dc.date"], named["dcterms.created"],
+		doc.Find("time[pubdate], time[itemprop=datePublished]").First().AttrOr("datetime", ""))
+	m.ModifiedTime = firstNonEmpty(m.OpenGraph["article:modified_time"], m.OpenGraph["updated_time"], ldString(ld, "dateModified"),
+		named["last-modified"], named["dcterms.modified"])
+	m.SiteName = firstNonEmpty(m.OpenGraph["site_name"], named["application-name"], ldString(ld, "publisher"))
+	if og := m.OpenGraph["type"]; og != "" {
+		m.Type = og
+	}
+	m.Image = resolveMetaURL(base, firstNonEmpty(m.OpenGraph["image"], m.OpenGraph["image:url"], m.Twitter["image"], ldString(ld, "image")))
+	for _, k := range strings.Split(firstNonEmpty(named["keywords"], ldString(ld, "keywords")), ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			m.Keywords = append(m.Keywords, k)
+		}
+	}
+
+	m.CanonicalURL = resolveMetaURL(base, firstNonEmpty(
+		doc.Find(`link[rel~="canonical"]`).First().AttrOr("href", ""),
+		m.OpenGraph["url"]))
+	m.Language = firstNonEmpty(
+		doc.Find("html").First().AttrOr("lang", ""),
+		doc.Find("html").First().AttrOr("xml:lang", ""),
+		named["language"], named["content-language"],
+		doc.Find(`meta[http-equiv="content-language" i]`).First().AttrOr("content", ""),
+		strings.ReplaceAll(m.OpenGraph["locale"], "_", "-"))
+	m.Language = strings.TrimSpace(m.Language)
+
+	if len(m.OpenGraph) == 0 {
+		m.OpenGraph = nil
+	}
+	if len(m.Twitter) == 0 {
+		m.Twitter = nil
+	}
+	if m.isEmpty() {
+		return nil
+	}
+	return m
+}
+
+func (m *pageMetadata) isEmpty() bool {
+	return m.Title == "" && m.Description == "" && m.CanonicalURL == "" && m.Language == "" &&
+		m.OpenGraph == nil && m.Twitter == nil && m.JSONLD == nil && m.PublishedTime == "" && m.Author == ""
+}
+
+// 解析页面中的 JSON-LD 脚本，展开数组与 @graph；无法解析的脚本跳过
+func documentJSONLD(doc *goquery.Document) []jsonLDItem {
+	var items []jsonLDItem
+	var collect func(v any)
+	collect = func(v any) {
+		switch v := v.(type) {
+		case []any:
+			for _, e := range v {
+				collect(e)
+			}
+		case map[string]any:
+			if graph, ok := v["@graph"]; ok {
+				collect(graph)
+				return
+			}
+			items = append(items, jsonLDItem{Type: ldType(v), Data: v})
+		}
+	}
+	doc.Find(`script[type="application/ld+json" i]`).Each(func(_ int, s *goquery.Selection) {
+		var v any
+		// 去掉部分站点包裹的 HTML 注释与 CDATA
+		raw := strings.TrimSpace(s.Text())
+		for _, affix := range [][2]string{{"<!--", "-->"}, {"//<![CDATA[", "//]]>"}, {"<![CDATA[", "]]>"}} {
+			raw = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(raw, affix[0]), affix[1]))
+		}
+		if err := json.Unmarshal([]byte(raw), &v); err == nil {
+			collect(v)
+		}
+	})
+	return items
+}
+
+// 描述网站本身或页面结构的类型，不作为页面的主要对象
+var auxiliaryLDTypes = map[string]bool{
+	"WebSite": true, "WebPage": true, "Organization": true, "BreadcrumbList": true,
+	"Person": true, "ImageObject": true, "SiteNavigationElement": true, "SearchAction": true,
+}
+
+// 取页面的主要对象：第一个不是辅助类型的对象，都是辅助类型时取第一个
+func primaryJSONLD(items []jsonLDItem) *jsonLDItem {
+	for i := range items {
+		if !auxiliaryLDTypes[items[i].Type] {
+			return &items[i]
+		}
+	}
+	if len(items) > 0 {
+		return &items[0]
+	}
+	return nil
+}
+
+// 取 @type，有多个类型时以逗号连接
+func ldType(v map[string]any) string {
+	switch t := v["@type"].(type) {
+	case string:
+		return t
+	case []any:
+		var types []string
+		for _, e := range t {
+			if s, ok := e.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return strings.Join(types, ",")
+	}
+	return ""
+}
+
+// 取 JSON-LD 字段的文本值：对象取 name、url 或 @value，数组取第一个元素，字符串数组以逗号连接
+func ldString(v map[string]any, key string) string {
+	if v == nil {
+		return ""
+	}
+	return ldValue(v[key])
+}
+
+func ldValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case map[string]any:
+		return firstNonEmpty(ldValue(v["name"]), ldValue(v["url"]), ldValue(v["@value"]))
+	case []any:
+		var parts []string
+		for _, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				// 作者等对象数组只取第一个
+				if len(parts) == 0 {
+					return ldValue(e)
+				}
+				break
+			}
+			parts = append(parts, strings.TrimSpace(s))
+		}
+		return strings.Join(parts, ", ")
+	}
+	return ""
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// 把元数据中的相对地址转为绝对地址
+func resolveMetaURL(base *net_url.URL, href string) string {
+	if href == "" || base == nil {
+		return href
+	}
+	ref, err := net_url.Parse(href)
+	if err != nil {
+		return href
+	}
+	return base.ResolveReference(ref).String()
+}
@@ -0,0 +1,618 @@
+package main
+
+import "C"
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	net_url "net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	// 单个搜索结果页的默认超时
+	defaultEngineTimeout = 20 * time.Second
+	// 默认返回的结果数
+	defaultMaxResults = 10
+	// 结果数上限
+	maxMaxResults = 100
+	// 单个引擎最多翻的页数
+	maxSearchPages = 10
+)
+
+// 搜索模式
+const (
+	searchModeFallback = "fallback"
+	searchModeMeta     = "meta"
+)
+
+// 搜索后端
+const (
+	backendAuto    = "auto"    // 有浏览器时使用浏览器，否则使用 HTTP
+	backendBrowser = "browser" // 用 chromedp 打开搜索结果页
+	backendHTTP    = "http"    // 直接请求 SearXNG 或 DuckDuckGo HTML，不需要浏览器
+)
+
+// 未指定引擎时的尝试顺序
+var defaultEngines = []string{"baidu", "bing", "duckduckgo"}
+
+// 搜索请求
+type searchQuery struct {
+	Keyword string
+	Page    int // 从 0 开始的页码
+	Filters searchFilters
+}
+
+// 搜索引擎：构造结果页 URL、给出结果加载完成的标志元素，并从结果页中提取结果
+type SearchEngine interface {
+	// 引擎名称，与选项中的 engines 对应
+	Name() string
+	// 搜索结果页的 URL，关键词须已转义，按 q.Page 翻页并应用支持的过滤条件
+	BuildURL(q searchQuery) string
+	// 已设置但本引擎无法表达的过滤条件
+	Unsupported(f searchFilters) []string
+	// 出现即表示结果页已加载的 CSS 选择器
+	WaitSelector() string
+	// 在结果页中执行的 JS，返回 [{title, url, snippet, source, date}]
+	ExtractScript() string
+}
+
+// 已注册的搜索引擎
+var searchEngines = map[string]SearchEngine{}
+
+func registerEngine(e SearchEngine) {
+	searchEngines[e.Name()] = e
+}
+
+func init() {
+	registerEngine(baiduEngine{})
+	registerEngine(bingEngine{})
+	registerEngine(duckDuckGoEngine{})
+	registerEngine(sogouEngine{})
+	registerEngine(googleEngine{})
+}
+
+// 搜索选项，由 SearchWithOptions 与 SessionSearch 的 optionsJSON 传入
+type searchOptions struct {
+	Engines       []string `json:"engines"`        // 使用的引擎；fallback 模式下按顺序尝试，前一个失败或无结果时使用下一个
+	Mode          string   `json:"mode"`           // fallback（默认）或 meta：meta 模式并行查询所有引擎并合并结果
+	Backend       string   `json:"backend"`        // auto（默认）、browser 或 http
+	SearxngURL    string   `json:"searxng_url"`    // HTTP 后端使用的 SearXNG 实例，留空时读取环境变量 SEARXNG_URL
+	DuckDuckGoURL string   `json:"duckduckgo_url"` // HTTP 后端使用的 DuckDuckGo 无 JS 版本地址，留空时使用官方地址
+	TimeoutSecs   int      `json:"timeout_secs"`   // 单个结果页的超时，0 表示使用默认值
+	MaxResults    int      `json:"max_results"`    // 返回的结果数，不足时自动翻页，0 表示使用默认值
+	// 是否提取摘要，默认 true
+	Snippets *bool `json:"snippets"`
+	// 是否解析跳转链接得到真实地址，默认 true
+	ResolveRedirects *bool `json:"resolve_redirects"`
+	// 过滤条件
+	Filters searchFilters `json:"filters"`
+}
+
+// 可配置搜索的返回数据
+type searchResponse struct {
+	Results []searchResult `json:"results"`
+	// 各尝试过的引擎不支持、因而未生效的过滤条件
+	UnsupportedFilters map[string][]string `json:"unsupported_filters,omitempty"`
+	// meta 模式下失败的引擎及其错误
+	EngineErrors map[string]*errorInfo `json:"engine_errors,omitempty"`
+}
+
+func parseSearchOptions(raw string) (searchOptions, error) {
+	var opts searchOptions
+	if strings.TrimSpace(raw) != "" {
+		if err := json.Unmarshal([]byte(raw), &opts); err != nil {
+			return opts, newError(codeInvalidArgument, "搜索选项不是合法的 JSON", err)
+		}
+	}
+	if opts.TimeoutSecs < 0 || opts.MaxResults < 0 {
+		return opts, newError(codeInvalidArgument, "搜索选项中存在负数", nil)
+	}
+	if opts.MaxResults > maxMaxResults {
+		return opts, newError(codeInvalidArgument, fmt.Sprintf("max_results 不能超过 %d", maxMaxResults), nil)
+	}
+	// 去掉重复的引擎，否则 meta 模式下同一引擎的结果会被重复计分
+	engines := opts.Engines[:0]
+	for _, name := range opts.Engines {
+		name = strings.ToLower(strings.TrimSpace(name))
+		_, browserOK := searchEngines[name]
+		_, httpOK := httpSearchEngines[name]
+		if !browserOK && !httpOK {
+			return opts, newError(codeInvalidArgument, fmt.Sprintf("未知的搜索引擎: %s", name), nil)
+		}
+		if !slices.Contains(engines, name) {
+			engines = append(engines, name)
+		}
+	}
+	opts.Engines = engines
+	switch opts.Backend {
+	case "":
+		opts.Backend = backendAuto
+	case backendAuto, backendBrowser, backendHTTP:
+	default:
+		return opts, newError(codeInvalidArgument, fmt.Sprintf("backend 须为 auto、browser 或 http: %s", opts.Backend), nil)
+	}
+	if opts.SearxngURL == "" {
+		opts.SearxngURL = os.Getenv("SEARXNG_URL")
+	}
+	opts.SearxngURL = strings.TrimRight(strings.TrimSpace(opts.SearxngURL), "/")
+	opts.DuckDuckGoURL = strings.TrimSpace(opts.DuckDuckGoURL)
+	if opts.DuckDuckGoURL == "" {
+		opts.DuckDuckGoURL = duckDuckGoHTMLURL
+	}
+	switch opts.Mode {
+	case "":
+		opts.Mode = searchModeFallback
+	case searchModeFallback, searchModeMeta:
+	default:
+		return opts, newError(codeInvalidArgument, fmt.Sprintf("mode 须为 fallback 或 meta: %s", opts.Mode), nil)
+	}
+	if err := opts.Filters.validate(); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+func (o searchOptions) engineTimeout() time.Duration {
+	if o.TimeoutSecs > 0 {
+		return time.Duration(o.TimeoutSecs) * time.Second
+	}
+	return defaultEngineTimeout
+}
+
+func (o searchOptions) maxResults() int {
+	if o.MaxResults > 0 {
+		return o.MaxResults
+	}
+	return defaultMaxResults
+}
+
+func (o searchOptions) snippets() bool {
+	return o.Snippets == nil || *o.Snippets
+}
+
+// 浏览器后端使用的引擎，未指定时使用默认顺序
+func (o searchOptions) browserEngines() []string {
+	if len(o.Engines) == 0 {
+		return defaultEngines
+	}
+	var names []string
+	for _, name := range o.Engines {
+		if _, ok := searchEngines[name]; ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// HTTP 后端使用的引擎，未指定时先用配置的 SearXNG，再用 DuckDuckGo
+func (o searchOptions) httpEngines() []string {
+	var names []string
+	if len(o.Engines) == 0 {
+		if o.SearxngURL != "" {
+			names = append(names, "searxng")
+		}
+		return append(names, "duckduckgo")
+	}
+	for _, name := range o.Engines {
+		if _, ok := httpSearchEngines[name]; !ok {
+			continue
+		}
+		if name == "searxng" && o.SearxngURL == "" {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// 一个引擎在某个后端上的搜索入口
+type engineRunner struct {
+	name        string
+	unsupported []string // 本引擎不支持的已设置过滤条件
+	search      func(ctx context.Context, q searchQuery) ([]searchResult, error)
+}
+
+// 按后端构造所选引擎的搜索入口，跳过该后端不支持的引擎
+func buildRunners(backend string, opts searchOptions) []engineRunner {
+	var runners []engineRunner
+	if backend == backendHTTP {
+		for _, name := range opts.httpEngines() {
+			engine := httpSearchEngines[name]
+			runners = append(runners, engineRunner{
+				name:        name,
+				unsupported: engine.Unsupported(opts.Filters),
+				search: func(ctx context.Context, q searchQuery) ([]searchResult, error) {
+					return searchHTTP(ctx, engine, q, opts)
+				},
+			})
+		}
+		return runners
+	}
+
+	for _, name := range opts.browserEngines() {
+		engine := searchEngines[name]
+		runners = append(runners, engineRunner{
+			name:        name,
+			unsupported: engine.Unsupported(opts.Filters),
+			search: func(ctx context.Context, q searchQuery) ([]searchResult, error) {
+				if opts.Mode == searchModeMeta {
+					// 并行搜索时每个引擎使用独立的标签页，结束时关闭
+					tabCtx, cancel := chromedp.NewContext(ctx)
+					defer cancel()
+					ctx = tabCtx
+				}
+				return search(ctx, engine, q, opts)
+			},
+		})
+	}
+	return runners
+}
+
+// 按选项中的模式在指定后端上执行搜索
+func searchWithOptions(ctx context.Context, keyword string, opts searchOptions, backend string) (*searchResponse, error) {
+	runners := buildRunners(backend, opts)
+	if len(runners) == 0 {
+		return &searchResponse{Results: []searchResult{}},
+			newError(codeInvalidArgument, fmt.Sprintf("所选的搜索引擎都不支持 %s 后端", backend), nil)
+	}
+	q := searchQuery{Keyword: keyword, Filters: opts.Filters}
+	if opts.Mode == searchModeMeta {
+		return metaSearch(ctx, runners, q, opts)
+	}
+	return searchWithFallback(ctx, runners, q, opts)
+}
+
+// 记录引擎不支持的过滤条件
+func (r *searchResponse) noteUnsupported(runner engineRunner) {
+	if len(runner.unsupported) > 0 {
+		if r.UnsupportedFilters == nil {
+			r.UnsupportedFilters = make(map[string][]string)
+		}
+		r.UnsupportedFilters[runner.name] = runner.unsupported
+	}
+}
+
+// 填充 ResolvedURL：按选项解析跳转链接，或只做规范化
+func finishResults(ctx context.Context, results []searchResult, opts searchOptions) {
+	if opts.ResolveRedirects == nil || *opts.ResolveRedirects {
+		resolveResults(ctx, results)
+		return
+	}
+	for i := range results {
+		results[i].ResolvedURL = canonicalURL(results[i].URL)
+	}
+}
+
+// 按顺序依次尝试各引擎，返回第一个有结果的引擎的结果
+func searchWithFallback(ctx context.Context, runners []engineRunner, q searchQuery, opts searchOptions) (*searchResponse, error) {
+	resp := &searchResponse{Results: []searchResult{}}
+	var lastErr error
+	for _, runner := range runners {
+		if err := ctx.Err(); err != nil {
+			return resp, wrapError(err, codeNavTimeout, "搜索超时")
+		}
+		resp.noteUnsupported(runner)
+
+		results, err := runner.search(ctx, q)
+		if err != nil {
+			log.Printf("搜索引擎 %s 失败: %v", runner.name, err)
+			lastErr = err
+			continue
+		}
+		if len(results) == 0 {
+			log.Printf("搜索引擎 %s 没有结果", runner.name)
+			continue
+		}
+		finishResults(ctx, results, opts)
+		resp.Results = results
+		return resp, nil
+	}
+	return resp, lastErr
+}
+
+// 结果页中各部分的 CSS 选择器，用于生成提取脚本
+type resultSelectors struct {
+	Item      string `json:"item"`      // 每条结果
+	Link      string `json:"link"`      // 结果内的链接，为空表示 Item 本身就是链接
+	Title     string `json:"title"`     // 链接内的标题，为空表示使用链接文本
+	Container string `json:"container"` // 从链接向上查找的结果容器，为空表示使用 Item
+	Snippet   string `json:"snippet"`
+	Source    string `json:"source"`
+	Date      string `json:"date"`
+}
+
+// 按选择器生成提取结果的 JS，同一页内按链接去重
+func buildExtractScript(sel resultSelectors) string {
+	data, _ := json.Marshal(sel)
+	return `(function(sel) {
+		const text = (root, s) => {
+			const el = root && s ? root.querySelector(s) : null;
+			return el ? el.innerText.replace(/\s+/g, ' ').trim() : '';
+		};
+		const seen = new Set();
+		return Array.from(document.querySelectorAll(sel.item)).map(item => {
+			const a = sel.link ? item.querySelector(sel.link) : item;
+			if (!a || !a.href || seen.has(a.href)) {
+				return null;
+			}
+			seen.add(a.href);
+			const box = (sel.container && a.closest(sel.container)) || item;
+			const titleEl = (sel.title && a.querySelector(sel.title)) || a;
+			return {
+				title: titleEl.innerText.trim(),
+				url: a.href,
+				snippet: text(box, sel.snippet),
+				source: text(box, sel.source),
+				date: text(box, sel.date)
+			};
+		}).filter(Boolean);
+	})(` + string(data) + `)`
+}
+
+// 百度
+type baiduEngine struct{}
+
+func (baiduEngine) Name() string { return "baidu" }
+
+func (baiduEngine) BuildURL(q searchQuery) string {
+	params := net_url.Values{"ie": {"UTF-8"}, "wd": {keywordWithSites(q, false)}}
+	if q.Page > 0 {
+		params.Set("pn", strconv.Itoa(q.Page*10))
+	}
+	if q.Filters.Freshness != "" {
+		now := time.Now()
+		params.Set("gpc", fmt.Sprintf("stf=%d,%d|stftype=1", q.Filters.since(now).Unix(), now.Unix()))
+	}
+	return "https://www.baidu.com/s?" + params.Encode()
+}
+
+func (baiduEngine) Unsupported(f searchFilters) []string {
+	unsupported := unsupportedFilters(f, filterSites, filterFreshness)
+	if len(f.Sites) > 1 {
+		// 百度只支持单个 site:，多出的站点被忽略
+		unsupported = append([]string{filterSites}, unsupported...)
+	}
+	return unsupported
+}
+
+func (baiduEngine) WaitSelector() string { return "#content_left" }
+
+func (baiduEngine) ExtractScript() string {
+	return buildExtractScript(resultSelectors{
+		Item:      "#content_left h3.t a",
+		Container: ".c-container, .result, .result-op",
+		Snippet:   `.c-abstract, [class*="content-right"], .c-span-last, [data-module="abstract"]`,
+		Source:    `.c-showurl, [class*="site-name"], [class*="source-text"], .c-color-gray`,
+		Date:      `.c-color-gray2, [class*="newTimeFactor"], [class*="time-text"]`,
+	})
+}
+
+// 必应
+type bingEngine struct{}
+
+func (bingEngine) Name() string { return "bing" }
+
+func (bingEngine) BuildURL(q searchQuery) string {
+	params := net_url.Values{"q": {keywordWithSites(q, true)}}
+	if q.Page > 0 {
+		params.Set("first", strconv.Itoa(q.Page*10+1))
+	}
+	switch q.Filters.Freshness {
+	case "day":
+		params.Set("filters", `ex1:"ez1"`)
+	case "week":
+		params.Set("filters", `ex1:"ez2"`)
+	case "month":
+		params.Set("filters", `ex1:"ez3"`)
+	case "year":
+		// 自定义范围，以 1970-01-01 起的天数表示
+		now := time.Now()
+		params.Set("filters", fmt.Sprintf(`ex1:"ez5_%d_%d"`, q.Filters.since(now).Unix()/86400, now.Unix()/86400))
+	}
+	if q.Filters.Language != "" {
+		params.Set("setlang", q.Filters.Language)
+	}
+	if q.Filters.Region != "" {
+		params.Set("cc", q.Filters.Region)
+	}
+	if q.Filters.SafeSearch != "" {
+		params.Set("adlt", q.Filters.SafeSearch)
+	}
+	return "https://www.bing.com/search?" + params.Encode()
+}
+
+func (bingEngine) Unsupported(f searchFilters) []string {
+	return unsupportedFilters(f, filterSites, filterExcludeSites, filterFreshness, filterLanguage, filterRegion, filterSafeSearch)
+}
+
+func (bingEngine) WaitSelector() string { return "#b_results" }
+
+func (bingEngine) ExtractScript() string {
+	return buildExtractScript(resultSelectors{
+		Item:    "#b_results > li.b_algo",
+		Link:    "h2 a",
+		Snippet: ".b_caption p, .b_lineclamp2, .b_lineclamp3, .b_algoSlug",
+		Source:  ".tptt, cite",
+		Date:    ".news_dt, .b_caption p span.news_dt",
+	})
+}
+
+// DuckDuckGo 的无 JS 版本
+type duckDuckGoEngine struct{}
+
+func (duckDuckGoEngine) Name() string { return "duckduckgo" }
+
+// DuckDuckGo 无 JS 版本的地址
+const duckDuckGoHTMLURL = "https://html.duckduckgo.com/html/"
+
+func (e duckDuckGoEngine) BuildURL(q searchQuery) string {
+	return e.buildURL(duckDuckGoHTMLURL, q)
+}
+
+func (duckDuckGoEngine) buildURL(base string, q searchQuery) string {
+	params := net_url.Values{"q": {keywordWithSites(q, true)}}
+	if q.Page > 0 {
+		// 无 JS 版本每页约 30 条结果，s 为跳过的结果数
+		params.Set("s", strconv.Itoa(q.Page*30))
+	}
+	if q.Filters.Freshness != "" {
+		params.Set("df", q.Filters.Freshness[:1])
+	}
+	if q.Filters.Region != "" {
+		// 地区参数形如 us-en，未指定语言时使用英语
+		lang := q.Filters.languagePrefix()
+		if lang == "" {
+			lang = "en"
+		}
+		params.Set("kl", q.Filters.Region+"-"+lang)
+	}
+	switch q.Filters.SafeSearch {
+	case "off":
+		params.Set("kp", "-2")
+	case "moderate":
+		params.Set("kp", "-1")
+	case "strict":
+		params.Set("kp", "1")
+	}
+	return base + "?" + params.Encode()
+}
+
+func (duckDuckGoEngine) Unsupported(f searchFilters) []string {
+	supported := []string{filterSites, filterExcludeSites, filterFreshness, filterRegion, filterSafeSearch}
+	if f.Region != "" {
+		// 语言只能与地区组合使用
+		supported = append(supported, filterLanguage)
+	}
+	return unsupportedFilters(f, supported...)
+}
+
+func (duckDuckGoEngine) WaitSelector() string { return "#links" }
+
+func (duckDuckGoEngine) ExtractScript() string {
+	return buildExtractScript(resultSelectors{
+		Item:    "#links .result:not(.result--ad)",
+		Link:    "a.result__a",
+		Snippet: ".result__snippet",
+		Source:  ".result__url",
+		Date:    ".result__timestamp",
+	})
+}
+
+// 搜狗
+type sogouEngine struct{}
+
+func (sogouEngine) Name() string { return "sogou" }
+
+// 搜狗的时间范围参数
+var sogouFreshness = map[string]string{"day": "1", "week": "2", "month": "3", "year": "4"}
+
+func (sogouEngine) BuildURL(q searchQuery) string {
+	params := net_url.Values{"query": {keywordWithSites(q, false)}}
+	if q.Page > 0 {
+		params.Set("page", strconv.Itoa(q.Page+1))
+	}
+	if tsn, ok := sogouFreshness[q.Filters.Freshness]; ok {
+		params.Set("tsn", tsn)
+	}
+	return "https://www.sogou.com/web?" + params.Encode()
+}
+
+func (sogouEngine) Unsupported(f searchFilters) []string {
+	unsupported := unsupportedFilters(f, filterSites, filterFreshness)
+	if len(f.Sites) > 1 {
+		unsupported = append([]string{filterSites}, unsupported...)
+	}
+	return unsupported
+}
+
+func (sogouEngine) WaitSelector() string { return "#main" }
+
+func (sogouEngine) ExtractScript() string {
+	return buildExtractScript(resultSelectors{
+		Item:    "#main .vrwrap, #main .rb",
+		Link:    "h3 a",
+		Snippet: ".space-txt, .str-text-info, .str_info, .ft",
+		Source:  ".citeurl span, cite",
+		Date:    ".cite-date, .citeurl .gray-color",
+	})
+}
+
+// 谷歌
+type googleEngine struct{}
+
+func (googleEngine) Name() string { return "google" }
+
+func (googleEngine) BuildURL(q searchQuery) string {
+	params := net_url.Values{"q": {keywordWithSites(q, true)}}
+	if q.Page > 0 {
+		params.Set("start", strconv.Itoa(q.Page*10))
+	}
+	if q.Filters.Freshness != "" {
+		params.Set("tbs", "qdr:"+q.Filters.Freshness[:1])
+	}
+	if q.Filters.Language != "" {
+		params.Set("hl", q.Filters.Language)
+		params.Set("lr", "lang_"+q.Filters.languagePrefix())
+	}
+	if q.Filters.Region != "" {
+		params.Set("gl", q.Filters.Region)
+	}
+	switch q.Filters.SafeSearch {
+	case "off":
+		params.Set("safe", "off")
+	case "moderate", "strict":
+		// 谷歌只有开关两档
+		params.Set("safe", "active")
+	}
+	return "https://www.google.com/search?" + params.Encode()
+}
+
+func (googleEngine) Unsupported(f searchFilters) []string {
+	return unsupportedFilters(f, filterSites, filterExcludeSites, filterFreshness, filterLanguage, filterRegion, filterSafeSearch)
+}
+
+func (googleEngine) WaitSelector() string { return "#search" }
+
+func (googleEngine) ExtractScript() string {
+	return buildExtractScript(resultSelectors{
+		Item:      "#search a:has(> h3)",
+		Title:     "h3",
+		Container: "div.g, div[data-hveid]",
+		Snippet:   `.VwiC3b, [data-sncf], [style*="-webkit-line-clamp"]`,
+		Source:    "span.VuuXrf, cite",
+		Date:      ".LEwnzc span, span.YrbPuc",
+	})
+}
+
+// 导出可配置的搜索功能：按 optionsJSON 中的 engines 依次尝试各搜索引擎
+//
+// backend 为 auto 时，找不到浏览器则改用 HTTP 后端，查询 SearXNG 实例或 DuckDuckGo 的无 JS 版本。
+//
+// 返回结果信封 {ok, data, error}，data 为 {results, unsupported_filters, engine_errors}，
+// results 中每项为 {title, url, resolved_url, snippet, source, date, rank, engine}，调用方须以 FreeString 释放。
+//
+//export SearchWithOptions
+func SearchWithOptions(keyword *C.char, optionsJSON *C.char) *C.char {
+	goKeyword := C.GoString(keyword)
+	if strings.TrimSpace(goKeyword) == "" {
+		return toCResponse(nil, newError(codeInvalidArgument, "搜索关键词为空", nil))
+	}
+	opts, err := parseSearchOptions(C.GoString(optionsJSON))
+	if err != nil {
+		return toCResponse(nil, err)
+	}
+	resp, err := runSearch(goKeyword, opts)
+	if err != nil {
+		log.Printf("搜索功能执行失败: %v", err)
+	}
+	return toCResponse(resp, err)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 搜索过滤条件，各引擎映射为自己的查询参数或搜索运算符
+type searchFilters struct {
+	Sites        []string `json:"sites"`         // 只搜索这些站点
+	ExcludeSites []string `json:"exclude_sites"` // 排除这些站点
+	Freshness    string   `json:"freshness"`     // 时间范围：day、week、month、year
+	Language     string   `json:"language"`      // 结果语言，如 en、zh-CN
+	Region       string   `json:"region"`        // 结果地区，如 us、cn
+	SafeSearch   string   `json:"safe_search"`   // 安全搜索：off、moderate、strict
+}
+
+// 过滤条件名称，与 JSON 字段一致，用于报告不支持的条件
+const (
+	filterSites        = "sites"
+	filterExcludeSites = "exclude_sites"
+	filterFreshness    = "freshness"
+	filterLanguage     = "language"
+	filterRegion       = "region"
+	filterSafeSearch   = "safe_search"
+)
+
+// 各时间范围对应的时长
+var freshnessWindows = map[string]time.Duration{
+	"day":   24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+	"year":  365 * 24 * time.Hour,
+}
+
+func (f *searchFilters) validate() error {
+	f.Sites = cleanSites(f.Sites)
+	f.ExcludeSites = cleanSites(f.ExcludeSites)
+	f.Freshness = strings.ToLower(strings.TrimSpace(f.Freshness))
+	f.Language = strings.TrimSpace(f.Language)
+	f.Region = strings.ToLower(strings.TrimSpace(f.Region))
+	f.SafeSearch = strings.ToLower(strings.TrimSpace(f.SafeSearch))
+
+	if _, ok := freshnessWindows[f.Freshness]; f.Freshness != "" && !ok {
+		return newError(codeInvalidArgument, fmt.Sprintf("freshness 须为 day、week、month 或 year: %s", f.Freshness), nil)
+	}
+	switch f.SafeSearch {
+	case "", "off", "moderate", "strict":
+	default:
+		return newError(codeInvalidArgument, fmt.Sprintf("safe_search 须为 off、moderate 或 strict: %s", f.SafeSearch), nil)
+	}
+	return nil
+}
+
+// 去掉站点中的协议与路径分隔符，忽略空值
+func cleanSites(sites []string) []string {
+	var cleaned []string
+	for _, site := range sites {
+		site = strings.TrimSpace(site)
+		site = strings.TrimPrefix(strings.TrimPrefix(site, "https://"), "http://")
+		site = strings.TrimSuffix(site, "/")
+		if site != "" {
+			cleaned = append(cleaned, site)
+		}
+	}
+	return cleaned
+}
+
+// 语言代码的主要部分，如 zh-CN 得到 zh
+func (f searchFilters) languagePrefix() string {
+	lang, _, _ := strings.Cut(f.Language, "-")
+	return strings.ToLower(lang)
+}
+
+// 时间范围的起点
+func (f searchFilters) since(now time.Time) time.Time {
+	return now.Add(-freshnessWindows[f.Freshness])
+}
+
+// 将站点条件写入关键词；orSites 为 false 的引擎只支持单个 site: 且不支持 -site:
+func keywordWithSites(q searchQuery, orSites bool) string {
+	keyword := q.Keyword
+	sites := q.Filters.Sites
+	switch {
+	case len(sites) == 1 || (len(sites) > 1 && !orSites):
+		keyword += " site:" + sites[0]
+	case len(sites) > 1:
+		parts := make([]string, len(sites))
+		for i, site := range sites {
+			parts[i] = "site:" + site
+		}
+		keyword += " (" + strings.Join(parts, " OR ") + ")"
+	}
+	if orSites {
+		for _, site := range q.Filters.ExcludeSites {
+			keyword += " -site:" + site
+		}
+	}
+	return keyword
+}
+
+// 引擎不支持的已设置条件；supported 列出引擎支持的条件
+func unsupportedFilters(f searchFilters, supported ...string) []string {
+	isSet := map[string]bool{
+		filterSites:        len(f.Sites) > 0,
+		filterExcludeSites: len(f.ExcludeSites) > 0,
+		filterFreshness:    f.Freshness != "",
+		filterLanguage:     f.Language != "",
+		filterRegion:       f.Region != "",
+		filterSafeSearch:   f.SafeSearch != "",
+	}
+	for _, name := range supported {
+		delete(isSet, name)
+	}
+
+	var unsupported []string
+	for _, name := range []string{filterSites, filterExcludeSites, filterFreshness, filterLanguage, filterRegion, filterSafeSearch} {
+		if isSet[name] {
+			unsupported = append(unsupported, name)
+		}
+	}
+	return unsupported
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	net_url "net/url"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// 搜索页正文的最大字节数
+const maxSearchBody = 4 << 20
+
+// 不需要浏览器、直接通过 HTTP 请求搜索的引擎
+type httpSearchEngine interface {
+	// 引擎名称，与 searchOptions.Engines 中的名称一致
+	Name() string
+	// 请求一页结果
+	SearchPage(ctx context.Context, q searchQuery, opts searchOptions) ([]searchResult, error)
+	// 本引擎不支持、因而不会生效的已设置过滤条件
+	Unsupported(f searchFilters) []string
+}
+
+// 已注册的 HTTP 搜索引擎，按名称索引
+var httpSearchEngines = map[string]httpSearchEngine{}
+
+func registerHTTPEngine(engine httpSearchEngine) {
+	httpSearchEngines[engine.Name()] = engine
+}
+
+func init() {
+	registerHTTPEngine(searxngEngine{})
+	registerHTTPEngine(duckDuckGoHTMLEngine{})
+}
+
+// 超时由每页的 context 控制
+var searchHTTPClient = &http.Client{}
+
+// 使用指定的 HTTP 引擎执行搜索，按需翻页直到取得 opts.MaxResults 条结果
+func searchHTTP(ctx context.Context, engine httpSearchEngine, q searchQuery, opts searchOptions) ([]searchResult, error) {
+	return collectPages(engine.Name(), q, opts, func(q searchQuery) ([]searchResult, error) {
+		pageCtx, cancel := context.WithTimeout(ctx, opts.engineTimeout())
+		defer cancel()
+		return engine.SearchPage(pageCtx, q, opts)
+	})
+}
+
+// 请求一页搜索结果，返回正文；被拒绝时返回 BLOCKED_BY_POLICY，其余非 2xx 状态返回 NAV_FAILED
+func fetchSearchPage(ctx context.Context, link string, accept string, f searchFilters) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, newError(codeInvalidArgument, "搜索地址不合法", err)
+	}
+	req.Header.Set("User-Agent", resolveUserAgent)
+	req.Header.Set("Accept", accept)
+	if f.Language != "" {
+		req.Header.Set("Accept-Language", f.Language)
+	}
+
+	resp, err := searchHTTPClient.Do(req)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return nil, wrapError(err, codeNavTimeout, "请求搜索页超时")
+		}
+		return nil, newError(codeNavFailed, "请求搜索页失败", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests:
+		return nil, newError(codeBlockedByPolicy, fmt.Sprintf("搜索请求被拒绝: HTTP %d", resp.StatusCode), nil)
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return nil, newError(codeNavFailed, fmt.Sprintf("搜索页返回 HTTP %d", resp.StatusCode), nil)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSearchBody))
+	if err != nil {
+		return nil, wrapError(err, codeNavTimeout, "读取搜索页失败")
+	}
+	return body, nil
+}
+
+// 结果地址的主机名，作为来源
+func hostOf(link string) string {
+	u, err := net_url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// SearXNG 元搜索实例的 JSON API，地址由 searxng_url 选项或环境变量 SEARXNG_URL 指定
+type searxngEngine struct{}
+
+func (searxngEngine) Name() string { return "searxng" }
+
+// SearXNG 的安全搜索级别
+var searxngSafeSearch = map[string]string{"off": "0", "moderate": "1", "strict": "2"}
+
+func (searxngEngine) buildURL(base string, q searchQuery) string {
+	params := net_url.Values{
+		"q":      {keywordWithSites(q, true)},
+		"format": {"json"},
+	}
+	if q.Page > 0 {
+		params.Set("pageno", strconv.Itoa(q.Page+1))
+	}
+	if q.Filters.Freshness != "" {
+		params.Set("time_range", q.Filters.Freshness)
+	}
+	if q.Filters.Language != "" {
+		lang := q.Filters.Language
+		if q.Filters.Region != "" && !strings.Contains(lang, "-") {
+			// 地区以 zh-CN 这样的区域语言代码表示
+			lang += "-" + strings.ToUpper(q.Filters.Region)
+		}
+		params.Set("language", lang)
+	}
+	if level, ok := searxngSafeSearch[q.Filters.SafeSearch]; ok {
+		params.Set("safesearch", level)
+	}
+	return base + "/search?" + params.Encode()
+}
+
+func (searxngEngine) Unsupported(f searchFilters) []string {
+	supported := []string{filterSites, filterExcludeSites, filterFreshness, filterLanguage, filterSafeSearch}
+	if f.Language != "" {
+		// 地区只能与语言组合使用
+		supported = append(supported, filterRegion)
+	}
+	return unsupportedFilters(f, supported...)
+}
+
+// SearXNG JSON API 返回的结果
+type searxngResponse struct {
+	Results []struct {
+		URL           string `json:"url"`
+		Title         string `json:"title"`
+		Content       string `json:"content"`
+		PublishedDate string `json:"publishedDate"`
+	} `json:"results"`
+}
+
+func (e searxngEngine) SearchPage(ctx context.Context, q searchQuery, opts searchOptions) ([]searchResult, error) {
+	if opts.SearxngURL == "" {
+		return nil, newError(codeInvalidArgument, "未配置 SearXNG 地址", nil)
+	}
+	body, err := fetchSearchPage(ctx, e.buildURL(opts.SearxngURL, q), "application/json", q.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	var data searxngResponse
+	if err := json.Unmarshal(body, &data); err != nil {
+		// 未启用 JSON 格式的实例会返回 HTML 页面
+		return nil, newError(codeNavFailed, "SearXNG 返回的不是 JSON，请确认实例已启用 json 格式", err)
+	}
+	results := make([]searchResult, 0, len(data.Results))
+	for _, r := range data.Results {
+		results = append(results, searchResult{
+			Title:   strings.TrimSpace(r.Title),
+			URL:     r.URL,
+			Snippet: strings.Join(strings.Fields(r.Content), " "),
+			Source:  hostOf(r.URL),
+			Date:    r.PublishedDate,
+		})
+	}
+	return results, nil
+}
+
+// DuckDuckGo 的无 JS 版本，默认与浏览器后端的 duckduckgo 使用相同的地址，可由 duckduckgo_url 选项替换
+type duckDuckGoHTMLEngine struct{}
+
+func (duckDuckGoHTMLEngine) Name() string { return "duckduckgo" }
+
+func (duckDuckGoHTMLEngine) Unsupported(f searchFilters) []string {
+	return duckDuckGoEngine{}.Unsupported(f)
+}
+
+func (duckDuckGoHTMLEngine) SearchPage(ctx context.Context, q searchQuery, opts searchOptions) ([]searchResult, error) {
+	endpoint := opts.DuckDuckGoURL
+	if endpoint == "" {
+		endpoint = duckDuckGoHTMLURL
+	}
+	link := duckDuckGoEngine{}.buildURL(endpoint, q)
+	body, err := fetchSearchPage(ctx, link, "text/html", q.Filters)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, newError(codeNavFailed, "解析搜索页失败", err)
+	}
+	if doc.Find(".anomaly-modal, #challenge-form").Length() > 0 {
+		return nil, newError(codeBlockedByPolicy, "DuckDuckGo 要求人机验证", nil)
+	}
+
+	base, _ := net_url.Parse(link)
+	text := func(s *goquery.Selection) string {
+		return strings.Join(strings.Fields(s.Text()), " ")
+	}
+	var results []searchResult
+	seen := make(map[string]bool)
+	doc.Find("#links .result:not(.result--ad)").Each(func(_ int, item *goquery.Selection) {
+		a := item.Find("a.result__a").First()
+		href, ok := a.Attr("href")
+		if !ok || href == "" {
+			return
+		}
+		// 链接为 //duckduckgo.com/l/?uddg=... 形式的相对地址
+		u, err := base.Parse(href)
+		if err != nil || seen[u.String()] {
+			return
+		}
+		seen[u.String()] = true
+		results = append(results, searchResult{
+			Title:   text(a),
+			URL:     u.String(),
+			Snippet: text(item.Find(".result__snippet").First()),
+			Source:  text(item.Find(".result__url").First()),
+			Date:    text(item.Find(".result__timestamp").First()),
+		})
+	})
+	return results, nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const searxngJSON = `{"results": [
+	{"url": "https://go.dev/", "title": "The Go Programming Language", "content": "Go is an open source\n programming language.", "publishedDate": "2024-01-02"},
+	{"url": "https://pkg.go.dev/", "title": "Go Packages", "content": "Search for Go packages."}
+]}`
+
+const duckDuckGoHTML = `<html><body><div id="links">
+	<div class="result result--ad"><a class="result__a" href="https://ads.example.com/">Ad</a></div>
+	<div class="result">
+		<a class="result__a" href="https://go.dev/">The Go  Programming Language</a>
+		<a class="result__snippet">Build simple, secure, scalable systems with Go.</a>
+		<a class="result__url">go.dev</a>
+	</div>
+	<div class="result">
+		<a class="result__a" href="https://en.wikipedia.org/wiki/Go_(programming_language)">Go (programming language)</a>
+		<a class="result__snippet">Go is a statically typed language.</a>
+	</div>
+</div></body></html>`
+
+// 以 httptest 模拟 SearXNG 与 DuckDuckGo；duckduckgo 为 nil 时该引擎返回 429
+func newSearchServer(t *testing.T, duckduckgo http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("format") != "json" || r.URL.Query().Get("q") != "golang" {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(searxngJSON))
+	})
+	mux.HandleFunc("/html/", func(w http.ResponseWriter, r *http.Request) {
+		if duckduckgo == nil {
+			http.Error(w, "rate limited", http.StatusTooManyRequests)
+			return
+		}
+		duckduckgo(w, r)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func serveDuckDuckGo(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("q") != "golang" {
+		http.Error(w, "bad query", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(duckDuckGoHTML))
+}
+
+func testSearchOptions(t *testing.T, srv *httptest.Server, raw string) searchOptions {
+	t.Helper()
+	opts, err := parseSearchOptions(raw)
+	if err != nil {
+		t.Fatalf("parseSearchOptions: %v", err)
+	}
+	opts.SearxngURL = srv.URL
+	opts.DuckDuckGoURL = srv.URL + "/html/"
+	return opts
+}
+
+func TestSearchHTTPMeta(t *testing.T) {
+	srv := newSearchServer(t, serveDuckDuckGo)
+	opts := testSearchOptions(t, srv, `{"engines": ["searxng", "duckduckgo", "searxng"], "mode": "meta", "resolve_redirects": false}`)
+
+	resp, err := searchWithOptions(context.Background(), "golang", opts, backendHTTP)
+	if err != nil {
+		t.Fatalf("searchWithOptions: %v", err)
+	}
+	if len(resp.Results) != 3 {
+		t.Fatalf("got %d results, want 3: %+v", len(resp.Results), resp.Results)
+	}
+	top := resp.Results[0]
+	if top.ResolvedURL != "https://go.dev/" || len(top.Engines) != 2 {
+		t.Errorf("top result = %s from %v, want https://go.dev/ from both engines", top.ResolvedURL, top.Engines)
+	}
+	if top.Snippet != "Build simple, secure, scalable systems with Go." {
+		t.Errorf("top snippet = %q, want the longer DuckDuckGo snippet", top.Snippet)
+	}
+	for _, r := range resp.Results {
+		if r.URL == "https://ads.example.com/" {
+			t.Errorf("ad result was not skipped")
+		}
+	}
+}
+
+func TestSearchHTTPFallback(t *testing.T) {
+	srv := newSearchServer(t, nil)
+	opts := testSearchOptions(t, srv, `{"engines": ["duckduckgo", "searxng"], "resolve_redirects": false}`)
+
+	resp, err := searchWithOptions(context.Background(), "golang", opts, backendHTTP)
+	if err != nil {
+		t.Fatalf("searchWithOptions: %v", err)
+	}
+	if len(resp.Results) != 2 || resp.Results[0].Engine != "searxng" {
+		t.Fatalf("results = %+v, want 2 results from searxng", resp.Results)
+	}
+	r := resp.Results[0]
+	if r.Snippet != "Go is an open source programming language." || r.Source != "go.dev" || r.Date != "2024-01-02" {
+		t.Errorf("unexpected result: %+v", r)
+	}
+
+	// 只选 DuckDuckGo 且被限流时返回 BLOCKED_BY_POLICY
+	opts.Engines = []string{"duckduckgo"}
+	if _, err := searchWithOptions(context.Background(), "golang", opts, backendHTTP); classifyError(err) != codeBlockedByPolicy {
+		t.Errorf("error = %v, want BLOCKED_BY_POLICY", err)
+	}
+}
+
+// auto 后端下所选引擎只支持 HTTP 时不应启动浏览器
+func TestRunSearchAutoHTTPOnlyEngine(t *testing.T) {
+	srv := newSearchServer(t, serveDuckDuckGo)
+	opts := testSearchOptions(t, srv, `{"engines": ["searxng"], "resolve_redirects": false}`)
+
+	resp, err := runSearch("golang", opts)
+	if err != nil {
+		t.Fatalf("runSearch: %v", err)
+	}
+	if len(resp.Results) != 2 || resp.Results[0].Engine != "searxng" {
+		t.Fatalf("results = %+v, want 2 results from searxng", resp.Results)
+	}
+}
+
+// 会话搜索在 auto 后端下所选引擎只支持 HTTP 时同样使用 HTTP 后端，不经过浏览器
+func TestSessionSearchAutoHTTPOnlyEngine(t *testing.T) {
+	srv := newSearchServer(t, serveDuckDuckGo)
+	opts := testSearchOptions(t, srv, `{"engines": ["searxng"], "resolve_redirects": false}`)
+
+	// 没有浏览器的会话：若走浏览器后端，搜索会失败
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	s := &session{id: "test", ctx: ctx, cancel: cancel, tabs: []*tab{{ctx: ctx, cancel: cancel}}}
+
+	resp, err := s.search("golang", opts)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(resp.Results) != 2 || resp.Results[0].Engine != "searxng" {
+		t.Fatalf("results = %+v, want 2 results from searxng", resp.Results)
+	}
+}
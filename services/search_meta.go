@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+)
+
+// 倒数排名融合的平滑常数，取常用值 60
+const rrfK = 60
+
+// 返回某条结果的引擎及其排名
+type engineRank struct {
+	Engine string `json:"engine"`
+	Rank   int    `json:"rank"`
+}
+
+// 并行查询所有引擎，按规范化 URL 去重并以倒数排名融合合并
+func metaSearch(ctx context.Context, runners []engineRunner, q searchQuery, opts searchOptions) (*searchResponse, error) {
+	resp := &searchResponse{Results: []searchResult{}}
+
+	type engineOutcome struct {
+		results []searchResult
+		err     error
+	}
+	outcomes := make([]engineOutcome, len(runners))
+	var wg sync.WaitGroup
+	for i, runner := range runners {
+		resp.noteUnsupported(runner)
+
+		wg.Add(1)
+		go func(i int, runner engineRunner) {
+			defer wg.Done()
+			results, err := runner.search(ctx, q)
+			if err == nil {
+				finishResults(ctx, results, opts)
+			}
+			outcomes[i] = engineOutcome{results: results, err: err}
+		}(i, runner)
+	}
+	wg.Wait()
+
+	var lastErr error
+	var lists [][]searchResult
+	for i, outcome := range outcomes {
+		name := runners[i].name
+		if outcome.err != nil {
+			log.Printf("搜索引擎 %s 失败: %v", name, outcome.err)
+			if resp.EngineErrors == nil {
+				resp.EngineErrors = make(map[string]*errorInfo)
+			}
+			resp.EngineErrors[name] = toErrorInfo(outcome.err)
+			lastErr = outcome.err
+			continue
+		}
+		lists = append(lists, outcome.results)
+	}
+	if len(lists) == 0 {
+		return resp, lastErr
+	}
+
+	resp.Results = mergeResults(lists, opts.maxResults())
+	return resp, nil
+}
+
+// 结果去重所用的键
+func resultKey(r searchResult) string {
+	if r.ResolvedURL != "" {
+		return r.ResolvedURL
+	}
+	return canonicalURL(r.URL)
+}
+
+// 同一引擎返回的重复 URL 只保留排名最好的一条，保持原有顺序
+func bestPerURL(results []searchResult) []searchResult {
+	index := make(map[string]int, len(results))
+	var unique []searchResult
+	for _, r := range results {
+		key := resultKey(r)
+		if i, ok := index[key]; ok {
+			if r.Rank < unique[i].Rank {
+				unique[i] = r
+			}
+			continue
+		}
+		index[key] = len(unique)
+		unique = append(unique, r)
+	}
+	return unique
+}
+
+// 按规范化 URL 合并各引擎的结果，得分为各引擎中 1/(k+rank) 之和，每个引擎对每个 URL 只计一次
+func mergeResults(lists [][]searchResult, maxResults int) []searchResult {
+	var merged []*searchResult
+	byURL := make(map[string]*searchResult)
+	for _, results := range lists {
+		for _, r := range bestPerURL(results) {
+			key := resultKey(r)
+			m, ok := byURL[key]
+			if !ok {
+				copied := r
+				copied.Engines = nil
+				copied.Score = 0
+				m = &copied
+				byURL[key] = m
+				merged = append(merged, m)
+			} else if len(r.Snippet) > len(m.Snippet) {
+				// 保留信息更完整的摘要
+				m.Snippet = r.Snippet
+			}
+			if m.Source == "" {
+				m.Source = r.Source
+			}
+			if m.Date == "" {
+				m.Date = r.Date
+			}
+			m.Engines = append(m.Engines, engineRank{Engine: r.Engine, Rank: r.Rank})
+			m.Score += 1.0 / float64(rrfK+r.Rank)
+		}
+	}
+
+	// 得分相同时，被更多引擎返回、最好排名更靠前的结果优先
+	sort.SliceStable(merged, func(i, j int) bool {
+		a, b := merged[i], merged[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if len(a.Engines) != len(b.Engines) {
+			return len(a.Engines) > len(b.Engines)
+		}
+		return bestRank(a) < bestRank(b)
+	})
+
+	if len(merged) > maxResults {
+		merged = merged[:maxResults]
+	}
+	results := make([]searchResult, len(merged))
+	for i, m := range merged {
+		results[i] = *m
+		results[i].Rank = i + 1
+	}
+	return results
+}
+
+func bestRank(r *searchResult) int {
+	best := 0
+	for _, e := range r.Engines {
+		if best == 0 || e.Rank < best {
+			best = e.Rank
+		}
+	}
+	return best
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// 同一引擎返回的重复 URL 只按最好的排名计一次
+func TestMergeResultsDuplicateURL(t *testing.T) {
+	bing := []searchResult{
+		{URL: "https://go.dev/", Rank: 1, Engine: "bing", Snippet: "short"},
+		{URL: "https://pkg.go.dev/", Rank: 2, Engine: "bing"},
+		{URL: "https://GO.dev/?utm_source=bing#top", Rank: 3, Engine: "bing", Snippet: "a longer snippet"},
+	}
+	google := []searchResult{
+		{URL: "https://pkg.go.dev/", Rank: 1, Engine: "google"},
+	}
+
+	merged := mergeResults([][]searchResult{bing, google}, 10)
+	if len(merged) != 2 {
+		t.Fatalf("got %d results, want 2: %+v", len(merged), merged)
+	}
+	byURL := make(map[string]searchResult)
+	for _, r := range merged {
+		byURL[resultKey(r)] = r
+	}
+
+	goDev := byURL["https://go.dev/"]
+	if want := []engineRank{{Engine: "bing", Rank: 1}}; !reflect.DeepEqual(goDev.Engines, want) {
+		t.Errorf("go.dev engines = %v, want %v", goDev.Engines, want)
+	}
+	if want := 1.0 / float64(rrfK+1); goDev.Score != want {
+		t.Errorf("go.dev score = %v, want %v", goDev.Score, want)
+	}
+	if goDev.Snippet != "short" {
+		t.Errorf("go.dev snippet = %q, want the snippet of the best-ranked duplicate", goDev.Snippet)
+	}
+
+	pkg := byURL["https://pkg.go.dev/"]
+	if len(pkg.Engines) != 2 || pkg.Score != 1.0/float64(rrfK+2)+1.0/float64(rrfK+1) {
+		t.Errorf("pkg.go.dev = %+v, want one entry from each engine", pkg)
+	}
+	if merged[0].URL != "https://pkg.go.dev/" {
+		t.Errorf("top result = %s, want the URL returned by both engines", merged[0].URL)
+	}
+}
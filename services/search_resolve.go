@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	net_url "net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// 同时解析的跳转链接数
+	resolveConcurrency = 8
+	// 单个链接的解析超时
+	resolveTimeout = 8 * time.Second
+	// 最多跟随的跳转次数
+	maxResolveHops = 5
+	// 从跳转页正文中查找目标地址时最多读取的字节数
+	maxRedirectBody = 64 << 10
+)
+
+// 请求跳转页时使用的 User-Agent，部分搜索引擎会拒绝默认的 Go User-Agent
+const resolveUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0 Safari/537.36"
+
+// 需要请求网络才能得到目标地址的跳转链接：host 后缀与路径前缀
+var redirectHosts = []struct {
+	host string
+	path string
+}{
+	{"baidu.com", "/link"},
+	{"sogou.com", "/link"},
+	{"so.com", "/link"},
+}
+
+// 跳转页正文中的 JS 跳转或 meta refresh
+var (
+	jsRedirectRe   = regexp.MustCompile(`(?:location\.replace|location\.href\s*=|window\.location\s*=)\s*\(?\s*["']([^"']+)["']`)
+	metaRedirectRe = regexp.MustCompile(`(?i)<meta[^>]+http-equiv=["']?refresh["']?[^>]+content=["'][^"']*url=([^"'>]+)`)
+)
+
+// 跳转地址中常见的跟踪参数，规范化时去除
+var trackingParams = []string{"utm_source", "utm_medium", "utm_campaign", "utm_term", "utm_content", "spm", "fbclid", "gclid"}
+
+// 不需要联网即可解码的跳转链接：DuckDuckGo、Google、必应
+func decodeRedirect(u *net_url.URL) (string, bool) {
+	host := strings.ToLower(u.Hostname())
+	q := u.Query()
+	switch {
+	case strings.HasSuffix(host, "duckduckgo.com") && strings.HasPrefix(u.Path, "/l/"):
+		if target := q.Get("uddg"); target != "" {
+			return target, true
+		}
+	case strings.Contains(host, "google.") && u.Path == "/url":
+		if target := q.Get("q"); target != "" {
+			return target, true
+		}
+		if target := q.Get("url"); target != "" {
+			return target, true
+		}
+	case strings.HasSuffix(host, "bing.com") && strings.HasPrefix(u.Path, "/ck/a"):
+		// u 参数为 "a1" 加上 base64url 编码的目标地址
+		if target := q.Get("u"); strings.HasPrefix(target, "a1") {
+			if decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(target[2:], "=")); err == nil {
+				return string(decoded), true
+			}
+		}
+	}
+	return "", false
+}
+
+func isRedirectLink(u *net_url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	for _, r := range redirectHosts {
+		if (host == r.host || strings.HasSuffix(host, "."+r.host)) && strings.HasPrefix(u.Path, r.path) {
+			return true
+		}
+	}
+	return false
+}
+
+// 规范化 URL：小写协议与主机名，去掉默认端口、片段与跟踪参数；其余查询参数保持原样，
+// 不重新排序或编码，以免破坏带签名或依赖参数顺序的链接
+func canonicalURL(raw string) string {
+	u, err := net_url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && !(u.Scheme == "http" && port == "80") && !(u.Scheme == "https" && port == "443") {
+		host += ":" + port
+	}
+	u.Host = host
+	u.Fragment = ""
+	u.RawFragment = ""
+	u.RawQuery = stripTrackingParams(u.RawQuery)
+	u.ForceQuery = false
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u.String()
+}
+
+// 从原始查询串中删除跟踪参数，其余部分逐字节保留
+func stripTrackingParams(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	parts := strings.Split(rawQuery, "&")
+	kept := parts[:0]
+	for _, part := range parts {
+		key, _, _ := strings.Cut(part, "=")
+		if name, err := net_url.QueryUnescape(key); err == nil && slices.Contains(trackingParams, name) {
+			continue
+		}
+		kept = append(kept, part)
+	}
+	return strings.Join(kept, "&")
+}
+
+// 不自动跟随跳转的 HTTP 客户端，逐跳读取 Location
+var resolveClient = &http.Client{
+	Timeout: resolveTimeout,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// 请求一次跳转链接，返回下一跳地址；先用 HEAD，拿不到 Location 时用 GET 并读取有限的正文
+func nextHop(ctx context.Context, link string) (string, error) {
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		req, err := http.NewRequestWithContext(ctx, method, link, nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("User-Agent", resolveUserAgent)
+		resp, err := resolveClient.Do(req)
+		if err != nil {
+			return "", err
+		}
+		location := resp.Header.Get("Location")
+		var body []byte
+		if location == "" && method == http.MethodGet {
+			body, _ = io.ReadAll(io.LimitReader(resp.Body, maxRedirectBody))
+		}
+		resp.Body.Close()
+
+		if location == "" && body != nil {
+			if m := jsRedirectRe.FindSubmatch(body); m != nil {
+				location = string(m[1])
+			} else if m := metaRedirectRe.FindSubmatch(body); m != nil {
+				location = html.UnescapeString(string(m[1]))
+			}
+		}
+		if location != "" {
+			base, _ := net_url.Parse(link)
+			next, err := base.Parse(location)
+			if err != nil {
+				return "", err
+			}
+			return next.String(), nil
+		}
+	}
+	return "", errors.New("跳转页中没有目标地址")
+}
+
+// 解析跳转链接，返回最终的目标地址；无需解析或解析失败时返回原地址
+func resolveRedirect(ctx context.Context, link string) (string, error) {
+	current := link
+	for hop := 0; hop < maxResolveHops; hop++ {
+		u, err := net_url.Parse(current)
+		if err != nil {
+			return current, err
+		}
+		if target, ok := decodeRedirect(u); ok {
+			current = target
+			continue
+		}
+		if !isRedirectLink(u) {
+			return current, nil
+		}
+		next, err := nextHop(ctx, current)
+		if err != nil {
+			return current, err
+		}
+		current = next
+	}
+	return current, nil
+}
+
+// 并发解析搜索结果中的跳转链接，填充 ResolvedURL
+func resolveResults(ctx context.Context, results []searchResult) {
+	sem := make(chan struct{}, resolveConcurrency)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(r *searchResult) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				r.ResolvedURL = canonicalURL(r.URL)
+				return
+			}
+
+			linkCtx, cancel := context.WithTimeout(ctx, resolveTimeout)
+			defer cancel()
+			resolved, err := resolveRedirect(linkCtx, r.URL)
+			if err != nil {
+				log.Printf("解析跳转链接失败: %s: %v", r.URL, err)
+			}
+			r.ResolvedURL = canonicalURL(resolved)
+		}(&results[i])
+	}
+	wg.Wait()
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	net_url "net/url"
+	"testing"
+)
+
+func TestCanonicalURL(t *testing.T) {
+	tests := []struct {
+		raw, want string
+	}{
+		{"HTTPS://Example.COM:443/Path?b=2&a=1#frag", "https://example.com/Path?b=2&a=1"},
+		{"http://example.com:80", "http://example.com/"},
+		{"http://example.com:8080/x", "http://example.com:8080/x"},
+		{"https://example.com/?utm_source=x&id=7&utm_medium=y&fbclid=z", "https://example.com/?id=7"},
+		{"https://example.com/a?utm_source=x&gclid=y", "https://example.com/a"},
+		{"https://example.com/a?", "https://example.com/a"},
+		// 其余参数的编码与顺序逐字节保留
+		{"https://example.com/s?sig=a%2Bb%3D&q=go+lang&spm=1.2&x=%7e", "https://example.com/s?sig=a%2Bb%3D&q=go+lang&x=%7e"},
+		{"https://example.com/s?flag&utm_campaign=c&=v&k=", "https://example.com/s?flag&=v&k="},
+		// 编码后的跟踪参数名同样去除
+		{"https://example.com/?utm%5Fsource=x&id=1", "https://example.com/?id=1"},
+		{"not a url", "not a url"},
+		{"/relative/path", "/relative/path"},
+	}
+	for _, tt := range tests {
+		if got := canonicalURL(tt.raw); got != tt.want {
+			t.Errorf("canonicalURL(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeRedirect(t *testing.T) {
+	target := "https://go.dev/doc/?a=1&b=2"
+	bing := "a1" + base64.RawURLEncoding.EncodeToString([]byte(target))
+	tests := []struct {
+		name, link, want string
+		ok               bool
+	}{
+		{"duckduckgo", "https://duckduckgo.com/l/?uddg=" + net_url.QueryEscape(target) + "&rut=abc", target, true},
+		{"duckduckgo protocol-relative", "//duckduckgo.com/l/?uddg=" + net_url.QueryEscape(target), target, true},
+		{"google q", "https://www.google.com/url?q=" + net_url.QueryEscape(target) + "&sa=U", target, true},
+		{"google url", "https://www.google.co.jp/url?url=" + net_url.QueryEscape(target), target, true},
+		{"bing", "https://www.bing.com/ck/a?!&&p=abc&u=" + bing + "&ntb=1", target, true},
+		{"bing padded", "https://www.bing.com/ck/a?u=" + bing + "==", target, true},
+		{"bing without a1 prefix", "https://www.bing.com/ck/a?u=" + base64.RawURLEncoding.EncodeToString([]byte(target)), "", false},
+		{"google search page", "https://www.google.com/search?q=go", "", false},
+		// 百度等的跳转链接不含目标地址，只能请求网络解析
+		{"baidu", "https://www.baidu.com/link?url=abcdef", "", false},
+		{"plain", target, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := net_url.Parse(tt.link)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := decodeRedirect(u)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("decodeRedirect(%q) = %q, %v; want %q, %v", tt.link, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestIsRedirectLink(t *testing.T) {
+	tests := map[string]bool{
+		"https://www.baidu.com/link?url=abc": true,
+		"http://baidu.com/link?url=abc":      true,
+		"https://www.sogou.com/link?url=abc": true,
+		"https://www.so.com/link?m=abc":      true,
+		"https://www.baidu.com/s?wd=go":      false,
+		"https://notbaidu.com/link?url=abc":  false,
+		"https://go.dev/link":                false,
+	}
+	for link, want := range tests {
+		u, _ := net_url.Parse(link)
+		if got := isRedirectLink(u); got != want {
+			t.Errorf("isRedirectLink(%q) = %v, want %v", link, got, want)
+		}
+	}
+}
+
+// 百度等的跳转页以 Location、JS 或 meta refresh 给出目标地址
+func TestNextHop(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/location", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/target?x=1", http.StatusFound)
+	})
+	mux.HandleFunc("/js", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			return
+		}
+		w.Write([]byte(`<script>window.location.replace("https://go.dev/js")</script>`))
+	})
+	mux.HandleFunc("/meta", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<meta http-equiv="refresh" content="0; URL=https://go.dev/meta?a=1&amp;b=2">`))
+	})
+	mux.HandleFunc("/empty", func(w http.ResponseWriter, r *http.Request) {})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		path, want string
+	}{
+		{"/location", srv.URL + "/target?x=1"},
+		{"/js", "https://go.dev/js"},
+		{"/meta", "https://go.dev/meta?a=1&b=2"},
+	}
+	for _, tt := range tests {
+		got, err := nextHop(context.Background(), srv.URL+tt.path)
+		if err != nil || got != tt.want {
+			t.Errorf("nextHop(%s) = %q, %v; want %q", tt.path, got, err, tt.want)
+		}
+	}
+	if _, err := nextHop(context.Background(), srv.URL+"/empty"); err == nil {
+		t.Errorf("nextHop(/empty) succeeded, want an error")
+	}
+}
+
+// 嵌套的跳转链接无需联网即可逐层解码
+func TestResolveRedirectNested(t *testing.T) {
+	target := "https://go.dev/"
+	ddg := "https://duckduckgo.com/l/?uddg=" + net_url.QueryEscape(target)
+	google := "https://www.google.com/url?q=" + net_url.QueryEscape(ddg)
+	got, err := resolveRedirect(context.Background(), google)
+	if err != nil || got != target {
+		t.Errorf("resolveRedirect = %q, %v; want %q", got, err, target)
+	}
+}
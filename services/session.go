@@ -0,0 +1,519 @@
+package main
+
+import "C"
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// 单条会话命令的默认超时
+const defaultSessionTimeout = 60 * time.Second
+
+// 会话空闲多久后自动关闭的默认值
+const defaultSessionIdleTimeout = 30 * time.Minute
+
+// 浏览器启动选项，由 SessionOpen 的 optionsJSON 传入
+type browserOptions struct {
+	UserDataDir  string `json:"user_data_dir"` // 用户数据目录，留空则使用关闭时删除的临时目录
+	ExecPath     string `json:"exec_path"`     // 浏览器可执行文件，留空则自动查找
+	Headless     *bool  `json:"headless"`      // 是否无头运行，默认 true
+	Proxy        string `json:"proxy"`         // 代理服务器，如 socks5://127.0.0.1:1080
+	UserAgent    string `json:"user_agent"`    // 自定义 User-Agent
+	WindowWidth  int    `json:"window_width"`  // 窗口宽度
+	WindowHeight int    `json:"window_height"` // 窗口高度
+	TimeoutSecs  int    `json:"timeout_secs"`  // 单条命令的超时，0 表示使用默认值
+	CookiesFile  string `json:"cookies_file"`  // 启动后导入的 cookie 文件（cookies.txt 或 JSON）
+	IdleSecs     int    `json:"idle_secs"`     // 空闲多久后自动关闭会话，0 表示使用默认值
+}
+
+func parseBrowserOptions(raw string) (browserOptions, error) {
+	var opts browserOptions
+	if strings.TrimSpace(raw) != "" {
+		if err := json.Unmarshal([]byte(raw), &opts); err != nil {
+			return opts, newError(codeInvalidArgument, "会话选项不是合法的 JSON", err)
+		}
+	}
+	if opts.TimeoutSecs < 0 || opts.IdleSecs < 0 || opts.WindowWidth < 0 || opts.WindowHeight < 0 {
+		return opts, newError(codeInvalidArgument, "会话选项中存在负数", nil)
+	}
+	return opts, nil
+}
+
+func (o browserOptions) timeout() time.Duration {
+	if o.TimeoutSecs > 0 {
+		return time.Duration(o.TimeoutSecs) * time.Second
+	}
+	return defaultSessionTimeout
+}
+
+func (o browserOptions) idleTimeout() time.Duration {
+	if o.IdleSecs > 0 {
+		return time.Duration(o.IdleSecs) * time.Second
+	}
+	return defaultSessionIdleTimeout
+}
+
+// 启动浏览器，返回其首个标签页的 context；cancel 会关闭整个浏览器
+func newBrowserContext(parent context.Context, opts browserOptions) (context.Context, context.CancelFunc) {
+	allocOpts := []chromedp.ExecAllocatorOption{
+		chromedp.NoFirstRun,
+		chromedp.NoDefaultBrowserCheck,
+		chromedp.DisableGPU,
+	}
+	if opts.Headless == nil || *opts.Headless {
+		allocOpts = append(allocOpts, chromedp.Headless)
+	}
+	if opts.ExecPath != "" {
+		allocOpts = append(allocOpts, chromedp.ExecPath(opts.ExecPath))
+	}
+	if opts.UserDataDir != "" {
+		allocOpts = append(allocOpts, chromedp.UserDataDir(opts.UserDataDir))
+	}
+	if opts.Proxy != "" {
+		allocOpts = append(allocOpts, chromedp.ProxyServer(opts.Proxy))
+	}
+	if opts.UserAgent != "" {
+		allocOpts = append(allocOpts, chromedp.UserAgent(opts.UserAgent))
+	}
+	if opts.WindowWidth > 0 && opts.WindowHeight > 0 {
+		allocOpts = append(allocOpts, chromedp.WindowSize(opts.WindowWidth, opts.WindowHeight))
+	}
+
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(parent, allocOpts...)
+	ctx, cancel := chromedp.NewContext(allocCtx)
+	return ctx, func() {
+		cancel()
+		cancelAlloc()
+	}
+}
+
+// 启动 newBrowserContext 返回的浏览器；chromedp 启动失败时返回 exec 的错误，据此判断找不到浏览器
+func startBrowser(ctx context.Context) error {
+	err := chromedp.Run(ctx)
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist) {
+		return newError(codeBrowserNotFound, "找不到可启动的浏览器", err)
+	}
+	return wrapError(err, codeNavTimeout, "启动浏览器失败")
+}
+
+// chromedp 自动查找浏览器时尝试的程序名与路径
+func browserCandidates() []string {
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{
+			"/Applications/Chromium.app/Contents/MacOS/Chromium",
+			"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome",
+		}
+	case "windows":
+		return []string{
+			"chrome",
+			"chrome.exe",
+			`C:\Program Files (x86)\Google\Chrome\Application\chrome.exe`,
+			`C:\Program Files\Google\Chrome\Application\chrome.exe`,
+			filepath.Join(os.Getenv("USERPROFILE"), `AppData\Local\Google\Chrome\Application\chrome.exe`),
+			filepath.Join(os.Getenv("USERPROFILE"), `AppData\Local\Chromium\Application\chrome.exe`),
+		}
+	}
+	return []string{
+		"headless_shell",
+		"headless-shell",
+		"chromium",
+		"chromium-browser",
+		"google-chrome",
+		"google-chrome-stable",
+		"google-chrome-beta",
+		"google-chrome-unstable",
+		"/usr/bin/google-chrome",
+		"/usr/local/bin/chrome",
+		"/snap/bin/chromium",
+		"chrome",
+	}
+}
+
+// 是否能找到可启动的浏览器；execPath 为空时按 chromedp 的查找顺序检查
+func browserAvailable(execPath string) bool {
+	candidates := browserCandidates()
+	if execPath != "" {
+		candidates = []string{execPath}
+	}
+	for _, name := range candidates {
+		if _, err := exec.LookPath(name); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// 浏览器会话：一个长期存活的浏览器及其打开的标签页
+type session struct {
+	id   string
+	opts browserOptions
+	// 初始标签页的 context，取消它会关闭整个浏览器
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// 同一会话上的命令须串行执行
+	mu       sync.Mutex
+	lastUsed time.Time             // 最近一次执行命令的时间，空闲超时的会话会被自动关闭
+	frame    *cdp.Node             // 当前 frame 的 iframe 节点，nil 表示主 frame
+	refs     map[string]elementRef // 最近一次快照分配的元素引用
+
+	// 标签页列表可能被不持有 mu 的命令（如 dialog）读取，单独加锁
+	tabsMu  sync.Mutex
+	tabs    []*tab
+	current int
+}
+
+// 标签页及其事件监听器收集的状态
+type tab struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu           sync.Mutex
+	dialog       *page.EventJavascriptDialogOpening // 尚未处理的对话框
+	requests     []*requestRecord                   // 最近的网络请求
+	routes       []*networkRoute                    // 网络请求拦截规则
+	fetchEnabled bool
+	generation   int                     // 主 frame 每次导航加一，用于判断快照引用是否过期
+	interrupt    context.CancelCauseFunc // 正在执行的命令的取消函数，对话框打开时调用
+}
+
+// 对话框打开后页面被阻塞，正在执行的命令以此原因提前结束
+var errDialogOpened = errors.New("页面打开了对话框")
+
+// 注册标签页并开始监听其事件
+func (s *session) addTab(ctx context.Context, cancel context.CancelFunc) *tab {
+	t := &tab{ctx: ctx, cancel: cancel}
+	chromedp.ListenTarget(ctx, t.handleEvent)
+
+	s.tabsMu.Lock()
+	defer s.tabsMu.Unlock()
+	s.tabs = append(s.tabs, t)
+	return t
+}
+
+// 处理标签页事件：记录对话框与网络请求，并执行请求拦截规则
+func (t *tab) handleEvent(ev any) {
+	switch ev := ev.(type) {
+	case *page.EventJavascriptDialogOpening:
+		t.mu.Lock()
+		t.dialog = ev
+		if t.interrupt != nil {
+			t.interrupt(errDialogOpened)
+		}
+		t.mu.Unlock()
+	case *page.EventJavascriptDialogClosed:
+		t.mu.Lock()
+		t.dialog = nil
+		t.mu.Unlock()
+	case *page.EventFrameNavigated:
+		if ev.Frame.ParentID == "" {
+			t.mu.Lock()
+			t.generation++
+			t.mu.Unlock()
+		}
+	case *network.EventRequestWillBeSent:
+		t.recordRequest(ev)
+	case *network.EventResponseReceived:
+		t.recordResponse(ev)
+	case *network.EventLoadingFailed:
+		t.recordFailure(ev)
+	case *fetch.EventRequestPaused:
+		// 监听器中不能阻塞，须在新的 goroutine 中回应
+		go t.handlePaused(ev)
+	}
+}
+
+func (s *session) currentTab() *tab {
+	s.tabsMu.Lock()
+	defer s.tabsMu.Unlock()
+	return s.tabs[s.current]
+}
+
+// 在会话的当前标签页上执行 fn，持有会话锁并施加会话的单条命令超时
+func (s *session) run(fn func(ctx context.Context) error) error {
+	return s.runTimeout(s.opts.timeout(), fn)
+}
+
+// 与 run 相同，但使用指定的超时
+func (s *session) runTimeout(timeout time.Duration, fn func(ctx context.Context) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastUsed = time.Now()
+
+	if err := s.ctx.Err(); err != nil {
+		return newError(codeCancelled, fmt.Sprintf("会话已关闭: %s", s.id), err)
+	}
+	// 超时 context 是标签页 context 的子 context，超时不会关闭标签页
+	t := s.currentTab()
+	timeoutCtx, cancelTimeout := context.WithTimeout(t.ctx, timeout)
+	defer cancelTimeout()
+	ctx, cancel := context.WithCancelCause(timeoutCtx)
+	defer cancel(nil)
+
+	t.mu.Lock()
+	t.interrupt = cancel
+	t.mu.Unlock()
+	defer func() {
+		t.mu.Lock()
+		t.interrupt = nil
+		t.mu.Unlock()
+	}()
+
+	err := fn(ctx)
+	if err != nil && errors.Is(context.Cause(ctx), errDialogOpened) {
+		// 命令触发了对话框，视为执行成功，由调用方通过 dialog 命令处理
+		return nil
+	}
+	return err
+}
+
+// 会话注册表
+var (
+	sessionsMu sync.Mutex
+	sessions   = make(map[string]*session)
+	sessionSeq atomic.Int64
+)
+
+func openSession(opts browserOptions) (*session, error) {
+	ctx, cancel := newBrowserContext(context.Background(), opts)
+	// chromedp 在首次 Run 时才真正启动浏览器，这里提前启动以便尽早暴露错误
+	if err := startBrowser(ctx); err != nil {
+		cancel()
+		return nil, err
+	}
+	if opts.CookiesFile != "" {
+		if _, err := importCookiesFile(ctx, opts.CookiesFile, ""); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+
+	s := &session{
+		id:       fmt.Sprintf("session-%d", sessionSeq.Add(1)),
+		opts:     opts,
+		ctx:      ctx,
+		cancel:   cancel,
+		lastUsed: time.Now(),
+	}
+	s.addTab(ctx, cancel)
+	sessionsMu.Lock()
+	sessions[s.id] = s
+	sessionsMu.Unlock()
+	reaperOnce.Do(func() { go reapIdleSessions() })
+	return s, nil
+}
+
+func lookupSession(id string) (*session, error) {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	s, ok := sessions[id]
+	if !ok {
+		return nil, newError(codeNotFound, fmt.Sprintf("会话不存在: %s", id), nil)
+	}
+	return s, nil
+}
+
+func closeSession(id string) error {
+	sessionsMu.Lock()
+	s, ok := sessions[id]
+	delete(sessions, id)
+	sessionsMu.Unlock()
+	if !ok {
+		return newError(codeNotFound, fmt.Sprintf("会话不存在: %s", id), nil)
+	}
+
+	// 等待正在执行的命令结束后再关闭浏览器
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cancel()
+	return nil
+}
+
+// 调用方可能不关闭会话便退出，由后台任务关闭空闲超时的会话，避免浏览器进程一直存活
+var reaperOnce sync.Once
+
+func reapIdleSessions() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		sessionsMu.Lock()
+		candidates := make([]*session, 0, len(sessions))
+		for _, s := range sessions {
+			candidates = append(candidates, s)
+		}
+		sessionsMu.Unlock()
+
+		for _, s := range candidates {
+			if s.closeIfIdle(now) {
+				log.Printf("会话空闲超过 %v，已自动关闭: %s", s.opts.idleTimeout(), s.id)
+			}
+		}
+	}
+}
+
+// 会话空闲超时时将其注销并关闭浏览器；正在执行命令的会话不算空闲
+func (s *session) closeIfIdle(now time.Time) bool {
+	if !s.mu.TryLock() {
+		return false
+	}
+	defer s.mu.Unlock()
+	if now.Sub(s.lastUsed) < s.opts.idleTimeout() {
+		return false
+	}
+	sessionsMu.Lock()
+	registered := sessions[s.id] == s
+	if registered {
+		delete(sessions, s.id)
+	}
+	sessionsMu.Unlock()
+	if registered {
+		// 已由 SessionClose 关闭的会话无需再次关闭
+		s.cancel()
+	}
+	return registered
+}
+
+// 导出会话创建：启动一个长期存活的浏览器
+//
+// 会话空闲超过 idle_secs（默认 30 分钟）后自动关闭，之后使用该会话返回 NOT_FOUND。
+// 返回结果信封 {ok, data, error}，data 为 {session_id}，调用方须以 FreeString 释放。
+//
+//export SessionOpen
+func SessionOpen(optionsJSON *C.char) *C.char {
+	opts, err := parseBrowserOptions(C.GoString(optionsJSON))
+	if err != nil {
+		return toCResponse(nil, err)
+	}
+	s, err := openSession(opts)
+	if err != nil {
+		log.Printf("创建会话失败: %v", err)
+		return toCResponse(nil, err)
+	}
+	return toCResponse(map[string]string{"session_id": s.id}, nil)
+}
+
+// 导出会话关闭：关闭浏览器，使用临时用户数据目录时一并删除
+//
+// 返回结果信封 {ok, error}，调用方须以 FreeString 释放。
+//
+//export SessionClose
+func SessionClose(sessionID *C.char) *C.char {
+	return toCResponse(nil, closeSession(C.GoString(sessionID)))
+}
+
+// 导出会话内的访问功能：在会话的当前标签页中打开 URL，保留 cookie 与登录状态
+//
+// 返回结果信封 {ok, data, error}，data 与 VisitJSON 相同，调用方须以 FreeString 释放。
+//
+//export SessionVisit
+func SessionVisit(sessionID *C.char, url *C.char) *C.char {
+	return sessionVisit(C.GoString(sessionID), C.GoString(url), "")
+}
+
+// 导出会话内可配置的访问功能
+//
+// optionsJSON 与 VisitWithOptions 相同，可为空。
+// 返回结果信封 {ok, data, error}，data 与 VisitJSON 相同，调用方须以 FreeString 释放。
+//
+//export SessionVisitWithOptions
+func SessionVisitWithOptions(sessionID *C.char, url *C.char, optionsJSON *C.char) *C.char {
+	return sessionVisit(C.GoString(sessionID), C.GoString(url), C.GoString(optionsJSON))
+}
+
+func sessionVisit(sessionID, goURL, optionsJSON string) *C.char {
+	s, err := lookupSession(sessionID)
+	if err != nil {
+		return toCResponse(nil, err)
+	}
+	opts, err := parseVisitOptions(optionsJSON)
+	if err != nil {
+		return toCResponse(nil, err)
+	}
+	if opts.Cursor != "" {
+		return toCResponse(visitChunk(opts.Cursor))
+	}
+	if strings.TrimSpace(goURL) == "" {
+		return toCResponse(nil, newError(codeInvalidArgument, "URL 为空", nil))
+	}
+	if opts.Fetch == fetchHTTP {
+		// 不经过会话的浏览器，也不带会话的 cookie
+		ctx, cancel := context.WithTimeout(context.Background(), s.opts.timeout())
+		defer cancel()
+		result, _, err := fetchPage(ctx, goURL, opts)
+		return toCResponse(result, err)
+	}
+
+	var result *visitResult
+	err = s.run(func(ctx context.Context) error {
+		var err error
+		s.frame = nil
+		result, err = visitURL(ctx, goURL, opts)
+		return err
+	})
+	if err != nil {
+		log.Printf("会话 %s 访问失败: %v", s.id, err)
+	}
+	return toCResponse(result, err)
+}
+
+// 导出会话内的搜索功能
+//
+// optionsJSON 与 SearchWithOptions 相同，可为空。
+// 返回结果信封 {ok, data, error}，data 与 SearchWithOptions 相同，调用方须以 FreeString 释放。
+//
+//export SessionSearch
+func SessionSearch(sessionID *C.char, keyword *C.char, optionsJSON *C.char) *C.char {
+	s, err := lookupSession(C.GoString(sessionID))
+	if err != nil {
+		return toCResponse(nil, err)
+	}
+	goKeyword := C.GoString(keyword)
+	if strings.TrimSpace(goKeyword) == "" {
+		return toCResponse(nil, newError(codeInvalidArgument, "搜索关键词为空", nil))
+	}
+	opts, err := parseSearchOptions(C.GoString(optionsJSON))
+	if err != nil {
+		return toCResponse(nil, err)
+	}
+
+	resp, err := s.search(goKeyword, opts)
+	if err != nil {
+		log.Printf("会话 %s 搜索失败: %v", s.id, err)
+	}
+	return toCResponse(resp, err)
+}
+
+// 在会话的浏览器中搜索；auto 后端下所选引擎都不支持浏览器时使用 HTTP 后端
+func (s *session) search(keyword string, opts searchOptions) (*searchResponse, error) {
+	backend := backendBrowser
+	if opts.Backend == backendHTTP || (opts.Backend == backendAuto && len(opts.browserEngines()) == 0) {
+		backend = backendHTTP
+	}
+	var resp *searchResponse
+	err := s.run(func(ctx context.Context) error {
+		var err error
+		resp, err = searchWithOptions(ctx, keyword, opts, backend)
+		return err
+	})
+	return resp, err
+}
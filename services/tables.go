@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// 单独提取表格时的格式，Markdown 总是返回
+const (
+	tablesNone     = ""
+	tablesMarkdown = "markdown"
+	tablesCSV      = "csv"  // 另返回 CSV 文本
+	tablesJSON     = "json" // 另返回以表头为键的行
+)
+
+const (
+	// colspan 与 rowspan 的上限，与 HTML 规范一致
+	maxColspan = 1000
+	maxRowspan = 65534
+)
+
+// 单独提取的表格
+type extractedTable struct {
+	Index    int                 `json:"index"` // 在页面中的序号，从 0 开始
+	Caption  string              `json:"caption,omitempty"`
+	Headers  []string            `json:"headers"`
+	Markdown string              `json:"markdown"`
+	CSV      string              `json:"csv,omitempty"`
+	Rows     []map[string]string `json:"rows,omitempty"`
+}
+
+// 展开 rowspan 与 colspan 后的表格，合并单元格的内容在所占的每一格中重复
+type tableGrid struct {
+	caption string
+	head    [][]string // thead 中或开头全部由 th 组成的行
+	body    [][]string
+	columns int
+}
+
+// 解析表格，cellText 返回单元格的文字；不含嵌套表格中的行
+func parseTable(table *html.Node, cellText func(*html.Node) string) *tableGrid {
+	g := &tableGrid{}
+	for c := table.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.DataAtom == atom.Caption {
+			g.caption = strings.Join(strings.Fields(nodeText(c)), " ")
+			break
+		}
+	}
+
+	// 每列尚未用完的 rowspan
+	type span struct {
+		text string
+		left int
+	}
+	var spans []span
+	rows := tableRows(table)
+	for i, tr := range rows {
+		var row []string
+		col := 0
+		// 跳过被上方单元格占用的列
+		fill := func() {
+			for col < len(spans) && spans[col].left > 0 {
+				row = append(row, spans[col].text)
+				spans[col].left--
+				col++
+			}
+		}
+		allTH, cells := true, 0
+		for c := tr.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode || (c.DataAtom != atom.Td && c.DataAtom != atom.Th) {
+				continue
+			}
+			cells++
+			allTH = allTH && c.DataAtom == atom.Th
+			fill()
+			text := cellText(c)
+			colspan := spanAttr(c, "colspan", maxColspan)
+			rowspan := spanAttr(c, "rowspan", maxRowspan)
+			if attr(c, "rowspan") == "0" {
+				// rowspan 为 0 时延伸到表格末尾
+				rowspan = len(rows) - i
+			}
+			rowspan = min(rowspan, len(rows)-i)
+			for k := 0; k < colspan; k++ {
+				for len(spans) <= col {
+					spans = append(spans, span{})
+				}
+				spans[col] = span{text: text, left: rowspan - 1}
+				row = append(row, text)
+				col++
+			}
+		}
+		// 行尾被上方单元格占用的列
+		for ; col < len(spans); col++ {
+			if spans[col].left > 0 {
+				for len(row) < col {
+					row = append(row, "")
+				}
+				row = append(row, spans[col].text)
+				spans[col].left--
+			}
+		}
+		if len(row) == 0 {
+			continue
+		}
+		g.columns = max(g.columns, len(row))
+		inHead := tr.Parent != nil && tr.Parent.DataAtom == atom.Thead
+		if len(g.body) == 0 && (inHead || (cells > 0 && allTH)) {
+			g.head = append(g.head, row)
+		} else {
+			g.body = append(g.body, row)
+		}
+	}
+
+	for _, rows := range [][][]string{g.head, g.body} {
+		for i := range rows {
+			for len(rows[i]) < g.columns {
+				rows[i] = append(rows[i], "")
+			}
+		}
+	}
+	// 没有表头时以第一行作为表头
+	if len(g.head) == 0 && len(g.body) > 0 {
+		g.head, g.body = g.body[:1], g.body[1:]
+	}
+	return g
+}
+
+func spanAttr(n *html.Node, name string, limit int) int {
+	v, err := strconv.Atoi(strings.TrimSpace(attr(n, name)))
+	if err != nil || v < 1 {
+		return 1
+	}
+	return min(v, limit)
+}
+
+// 合并多行表头：每列取各行中不重复的文字，以 " / " 连接
+func (g *tableGrid) headers() []string {
+	headers := make([]string, g.columns)
+	for col := range headers {
+		var parts []string
+		for _, row := range g.head {
+			if text := row[col]; text != "" && (len(parts) == 0 || parts[len(parts)-1] != text) {
+				parts = append(parts, text)
+			}
+		}
+		headers[col] = strings.Join(parts, " / ")
+	}
+	return headers
+}
+
+// 渲染为 Markdown 表格；text 为 true 时以制表符分隔单元格
+func (g *tableGrid) markdown(text bool) string {
+	if g.columns == 0 {
+		return ""
+	}
+	var b strings.Builder
+	writeRow := func(row []string) {
+		if text {
+			b.WriteString(strings.Join(row, "\t") + "\n")
+			return
+		}
+		escaped := make([]string, len(row))
+		for i, cell := range row {
+			escaped[i] = strings.ReplaceAll(cell, "|", `\|`)
+		}
+		b.WriteString("| " + strings.Join(escaped, " | ") + " |\n")
+	}
+	writeRow(g.headers())
+	if !text {
+		b.WriteString("|" + strings.Repeat(" --- |", g.columns) + "\n")
+	}
+	for _, row := range g.body {
+		writeRow(row)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// 渲染为 CSV，第一行为表头
+func (g *tableGrid) csv() (string, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(g.headers()); err != nil {
+		return "", err
+	}
+	if err := w.WriteAll(g.body); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// 以表头为键的行；表头为空时以 column_N 为键，重复时加上序号
+func (g *tableGrid) records() []map[string]string {
+	keys := g.headers()
+	seen := make(map[string]int)
+	for i, key := range keys {
+		if key == "" {
+			key = fmt.Sprintf("column_%d", i+1)
+		}
+		seen[key]++
+		if n := seen[key]; n > 1 {
+			key = fmt.Sprintf("%s_%d", key, n)
+		}
+		keys[i] = key
+	}
+	records := make([]map[string]string, 0, len(g.body))
+	for _, row := range g.body {
+		record := make(map[string]string, len(keys))
+		for i, key := range keys {
+			record[key] = row[i]
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// 提取文档中的数据表格：跳过 role 为 presentation 的表格与包含嵌套表格的布局表格
+func documentTables(doc *goquery.Document, format string) ([]extractedTable, error) {
+	tables := []extractedTable{}
+	var err error
+	doc.Find("body table").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		if role := s.AttrOr("role", ""); role == "presentation" || role == "none" || s.Find("table").Length() > 0 {
+			return true
+		}
+		var table *extractedTable
+		if table, err = convertTable(s.Get(0), format); err != nil {
+			return false
+		}
+		if table != nil {
+			table.Index = len(tables)
+			tables = append(tables, *table)
+		}
+		return true
+	})
+	return tables, err
+}
+
+// 按 format 转换一个表格，表格为空时返回 nil
+func convertTable(n *html.Node, format string) (*extractedTable, error) {
+	g := parseTable(n, func(cell *html.Node) string {
+		return strings.Join(strings.Fields(tableCellText(cell)), " ")
+	})
+	if g.columns == 0 {
+		return nil, nil
+	}
+	table := &extractedTable{Caption: g.caption, Headers: g.headers(), Markdown: g.markdown(false)}
+	switch format {
+	case tablesCSV:
+		text, err := g.csv()
+		if err != nil {
+			return nil, newError(codeInternal, "生成 CSV 失败", err)
+		}
+		table.CSV = text
+	case tablesJSON:
+		table.Rows = g.records()
+	}
+	return table, nil
+}
+
+// 从 HTML 中提取表格
+func extractTables(pageHTML, format string) ([]extractedTable, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(pageHTML))
+	if err != nil {
+		return nil, newError(codeInternal, "解析页面 HTML 失败", err)
+	}
+	return documentTables(doc, format)
+}
+
+// 单元格中的文字，<br> 与块级元素之间以空格分隔
+func tableCellText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			b.WriteString(n.Data)
+		case html.ElementNode:
+			switch n.DataAtom {
+			case atom.Script, atom.Style, atom.Template:
+				return
+			case atom.Br, atom.P, atom.Div, atom.Li:
+				b.WriteString(" ")
+			}
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				walk(c)
+			}
+		}
+	}
+	walk(n)
+	return b.String()
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractTables(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		headers  []string
+		markdown string
+		csv      string
+		rows     []map[string]string
+	}{
+		{
+			name: "rowspan and colspan",
+			html: `<table><caption> Sales </caption>
+				<tr><th>A</th><th colspan="2">B</th></tr>
+				<tr><td rowspan="2">1</td><td>2</td><td>3</td></tr>
+				<tr><td>4</td><td>5</td></tr>
+			</table>`,
+			headers:  []string{"A", "B", "B"},
+			markdown: "| A | B | B |\n| --- | --- | --- |\n| 1 | 2 | 3 |\n| 1 | 4 | 5 |",
+			csv:      "A,B,B\n1,2,3\n1,4,5\n",
+			rows: []map[string]string{
+				{"A": "1", "B": "2", "B_2": "3"},
+				{"A": "1", "B": "4", "B_2": "5"},
+			},
+		},
+		{
+			name: "rowspan 0 extends to the end of the table",
+			html: `<table>
+				<tr><th>k</th><th>v</th></tr>
+				<tr><td rowspan="0">x</td><td>1</td></tr>
+				<tr><td>2</td></tr>
+				<tr><td>3</td></tr>
+			</table>`,
+			headers:  []string{"k", "v"},
+			markdown: "| k | v |\n| --- | --- |\n| x | 1 |\n| x | 2 |\n| x | 3 |",
+			csv:      "k,v\nx,1\nx,2\nx,3\n",
+			rows:     []map[string]string{{"k": "x", "v": "1"}, {"k": "x", "v": "2"}, {"k": "x", "v": "3"}},
+		},
+		{
+			name: "spans still open at the end of a row",
+			html: `<table>
+				<tr><th>a</th><th>b</th><th>c</th></tr>
+				<tr><td>1</td><td>2</td><td rowspan="2">R</td></tr>
+				<tr><td>3</td></tr>
+			</table>`,
+			headers:  []string{"a", "b", "c"},
+			markdown: "| a | b | c |\n| --- | --- | --- |\n| 1 | 2 | R |\n| 3 |  | R |",
+			csv:      "a,b,c\n1,2,R\n3,,R\n",
+			rows:     []map[string]string{{"a": "1", "b": "2", "c": "R"}, {"a": "3", "b": "", "c": "R"}},
+		},
+		{
+			name: "multi-row header",
+			html: `<table>
+				<thead>
+					<tr><th rowspan="2">Name</th><th colspan="2">Score</th></tr>
+					<tr><th>Math</th><th>Art</th></tr>
+				</thead>
+				<tbody><tr><td>Ann</td><td>90</td><td>80</td></tr></tbody>
+			</table>`,
+			headers:  []string{"Name", "Score / Math", "Score / Art"},
+			markdown: "| Name | Score / Math | Score / Art |\n| --- | --- | --- |\n| Ann | 90 | 80 |",
+			csv:      "Name,Score / Math,Score / Art\nAnn,90,80\n",
+			rows:     []map[string]string{{"Name": "Ann", "Score / Math": "90", "Score / Art": "80"}},
+		},
+		{
+			name: "duplicate and empty header keys",
+			html: `<table>
+				<tr><th></th><th>x</th><th>x</th><th></th></tr>
+				<tr><td>1</td><td>2</td><td>3</td><td>4</td></tr>
+			</table>`,
+			headers:  []string{"", "x", "x", ""},
+			markdown: "|  | x | x |  |\n| --- | --- | --- | --- |\n| 1 | 2 | 3 | 4 |",
+			csv:      ",x,x,\n1,2,3,4\n",
+			rows:     []map[string]string{{"column_1": "1", "x": "2", "x_2": "3", "column_4": "4"}},
+		},
+		{
+			name: "first row as header and cell escaping",
+			html: `<table>
+				<tr><td>name</td><td>note</td></tr>
+				<tr><td>a|b</td><td>say "hi",<br>bye</td></tr>
+			</table>`,
+			headers:  []string{"name", "note"},
+			markdown: "| name | note |\n| --- | --- |\n| a\\|b | say \"hi\", bye |",
+			csv:      "name,note\na|b,\"say \"\"hi\"\", bye\"\n",
+			rows:     []map[string]string{{"name": "a|b", "note": `say "hi", bye`}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, format := range []string{tablesMarkdown, tablesCSV, tablesJSON} {
+				tables, err := extractTables(`<html><body>`+tt.html+`</body></html>`, format)
+				if err != nil {
+					t.Fatalf("extractTables(%s): %v", format, err)
+				}
+				if len(tables) != 1 {
+					t.Fatalf("extractTables(%s) returned %d tables, want 1", format, len(tables))
+				}
+				table := tables[0]
+				if !reflect.DeepEqual(table.Headers, tt.headers) {
+					t.Errorf("headers = %q, want %q", table.Headers, tt.headers)
+				}
+				if table.Markdown != tt.markdown {
+					t.Errorf("markdown =\n%s\nwant\n%s", table.Markdown, tt.markdown)
+				}
+				switch format {
+				case tablesCSV:
+					if table.CSV != tt.csv {
+						t.Errorf("csv = %q, want %q", table.CSV, tt.csv)
+					}
+				case tablesJSON:
+					if !reflect.DeepEqual(table.Rows, tt.rows) {
+						t.Errorf("rows = %v, want %v", table.Rows, tt.rows)
+					}
+				}
+			}
+		})
+	}
+}
+
+// 布局表格与空表格不作为数据表格返回
+func TestExtractTablesSkipsLayoutTables(t *testing.T) {
+	page := `<html><body>
+		<table role="presentation"><tr><td>nav</td></tr></table>
+		<table><tr><td><table><tr><th>inner</th></tr><tr><td>1</td></tr></table></td></tr></table>
+		<table></table>
+		<table><caption>Data</caption><tr><th>h</th></tr><tr><td>v</td></tr></table>
+	</body></html>`
+	tables, err := extractTables(page, tablesMarkdown)
+	if err != nil {
+		t.Fatalf("extractTables: %v", err)
+	}
+	if len(tables) != 2 {
+		t.Fatalf("got %d tables, want the nested inner table and the captioned table: %+v", len(tables), tables)
+	}
+	if tables[0].Headers[0] != "inner" || tables[0].Index != 0 {
+		t.Errorf("first table = %+v, want the inner table at index 0", tables[0])
+	}
+	if tables[1].Caption != "Data" || tables[1].Index != 1 {
+		t.Errorf("second table = %+v, want caption Data at index 1", tables[1])
+	}
+}
@@ -0,0 +1,374 @@
+package main
+
+import "C"
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// 页面加载完成的判定方式
+const (
+	waitNetworkIdle      = "networkidle"      // DOMContentLoaded 之后，进行中的请求数持续不超过 idle_connections
+	waitDOMContentLoaded = "domcontentloaded" // 主文档解析完成
+	waitLoad             = "load"             // 主文档及其子资源加载完成
+	waitSelector         = "selector"         // 指定元素可见
+	waitPredicate        = "predicate"        // JS 表达式为真
+)
+
+const (
+	// 网络空闲时允许的进行中请求数，容忍长连接与统计上报
+	defaultIdleConnections = 2
+	// 网络需保持空闲的时长
+	defaultIdleWindow = 500 * time.Millisecond
+	// 等待加载的上限
+	defaultMaxWait = 15 * time.Second
+	// 检查加载状态的间隔
+	loadPollInterval = 50 * time.Millisecond
+)
+
+// 页面加载的等待方式
+type loadOptions struct {
+	WaitUntil       string `json:"wait_until"`       // networkidle（默认）、domcontentloaded、load、selector 或 predicate
+	Selector        string `json:"selector"`         // wait_until 为 selector 时等待可见的 CSS 选择器
+	Predicate       string `json:"predicate"`        // wait_until 为 predicate 时等待为真的 JS 表达式
+	IdleConnections *int   `json:"idle_connections"` // 网络空闲时允许的进行中请求数，默认 2
+	IdleMs          int    `json:"idle_ms"`          // 网络需保持空闲的毫秒数，0 表示使用默认值
+	// 等待加载的上限（毫秒），0 表示使用默认值；networkidle、domcontentloaded 与 load 到时后按已加载的内容继续，
+	// selector 与 predicate 到时后返回 SELECTOR_TIMEOUT
+	MaxWaitMs int `json:"max_wait_ms"`
+}
+
+// 访问选项，由 VisitWithOptions 与 SessionVisitWithOptions 的 optionsJSON 传入
+type visitOptions struct {
+	loadOptions
+	// auto（默认）先用 HTTP 获取，页面需要执行 JS 时改用浏览器；http 只用 HTTP；browser 只用浏览器。
+	// 会话内访问时 auto 等同于 browser
+	Fetch   string `json:"fetch"`
+	Extract string `json:"extract"` // page（默认）提取整个页面，main 只提取正文，links 只返回标题大纲与链接
+	Format  string `json:"format"`  // text 或 markdown；extract 为 page 时默认 text，为 main 时默认 markdown
+	// 另外单独返回页面中的数据表格：markdown 只返回 Markdown，csv 与 json 另返回 CSV 文本或以表头为键的行；
+	// 为空时不单独返回
+	Tables string `json:"tables"`
+	// 每块的最大字符数与估算的最大 token 数，0 表示不限；超出时按段落与标题边界分块，只返回第一块，
+	// metadata、outline 与 tables 只随第一块返回
+	MaxChars          int `json:"max_chars"`
+	MaxTokensEstimate int `json:"max_tokens_estimate"`
+	// 上一次返回的 next_cursor，设置后直接从缓存中返回对应的块，不重新加载页面
+	Cursor string `json:"cursor"`
+}
+
+func parseVisitOptions(raw string) (visitOptions, error) {
+	var opts visitOptions
+	if strings.TrimSpace(raw) != "" {
+		if err := json.Unmarshal([]byte(raw), &opts); err != nil {
+			return opts, newError(codeInvalidArgument, "访问选项不是合法的 JSON", err)
+		}
+	}
+	if err := opts.loadOptions.validate(); err != nil {
+		return opts, err
+	}
+
+	if opts.MaxChars < 0 || opts.MaxTokensEstimate < 0 {
+		return opts, newError(codeInvalidArgument, "访问选项中存在负数", nil)
+	}
+	opts.Cursor = strings.TrimSpace(opts.Cursor)
+
+	opts.Fetch = strings.ToLower(strings.TrimSpace(opts.Fetch))
+	switch opts.Fetch {
+	case "":
+		opts.Fetch = fetchAuto
+	case fetchAuto, fetchHTTP, fetchBrowser:
+	default:
+		return opts, newError(codeInvalidArgument, fmt.Sprintf("fetch 须为 auto、http 或 browser: %s", opts.Fetch), nil)
+	}
+
+	opts.Extract = strings.ToLower(strings.TrimSpace(opts.Extract))
+	opts.Format = strings.ToLower(strings.TrimSpace(opts.Format))
+	switch opts.Extract {
+	case "":
+		opts.Extract = extractPage
+	case extractPage, extractMain, extractLinks:
+	default:
+		return opts, newError(codeInvalidArgument, fmt.Sprintf("extract 须为 page、main 或 links: %s", opts.Extract), nil)
+	}
+	switch opts.Format {
+	case "":
+		opts.Format = formatText
+		if opts.Extract == extractMain {
+			opts.Format = formatMarkdown
+		}
+	case formatText, formatMarkdown:
+	default:
+		return opts, newError(codeInvalidArgument, fmt.Sprintf("format 须为 text 或 markdown: %s", opts.Format), nil)
+	}
+	opts.Tables = strings.ToLower(strings.TrimSpace(opts.Tables))
+	switch opts.Tables {
+	case tablesNone, tablesMarkdown, tablesCSV, tablesJSON:
+	default:
+		return opts, newError(codeInvalidArgument, fmt.Sprintf("tables 须为 markdown、csv 或 json: %s", opts.Tables), nil)
+	}
+	return opts, nil
+}
+
+func (o *loadOptions) validate() error {
+	o.WaitUntil = strings.ToLower(strings.TrimSpace(o.WaitUntil))
+	if o.WaitUntil == "" {
+		switch {
+		case o.Selector != "":
+			o.WaitUntil = waitSelector
+		case o.Predicate != "":
+			o.WaitUntil = waitPredicate
+		default:
+			o.WaitUntil = waitNetworkIdle
+		}
+	}
+	switch o.WaitUntil {
+	case waitNetworkIdle, waitDOMContentLoaded, waitLoad:
+	case waitSelector:
+		if strings.TrimSpace(o.Selector) == "" {
+			return newError(codeInvalidArgument, "wait_until 为 selector 时须指定 selector", nil)
+		}
+	case waitPredicate:
+		if strings.TrimSpace(o.Predicate) == "" {
+			return newError(codeInvalidArgument, "wait_until 为 predicate 时须指定 predicate", nil)
+		}
+	default:
+		return newError(codeInvalidArgument,
+			fmt.Sprintf("wait_until 须为 networkidle、domcontentloaded、load、selector 或 predicate: %s", o.WaitUntil), nil)
+	}
+	if (o.IdleConnections != nil && *o.IdleConnections < 0) || o.IdleMs < 0 || o.MaxWaitMs < 0 {
+		return newError(codeInvalidArgument, "加载选项中存在负数", nil)
+	}
+	return nil
+}
+
+func (o loadOptions) idleConnections() int {
+	if o.IdleConnections != nil {
+		return *o.IdleConnections
+	}
+	return defaultIdleConnections
+}
+
+func (o loadOptions) idleWindow() time.Duration {
+	if o.IdleMs > 0 {
+		return time.Duration(o.IdleMs) * time.Millisecond
+	}
+	return defaultIdleWindow
+}
+
+func (o loadOptions) maxWait() time.Duration {
+	if o.MaxWaitMs > 0 {
+		return time.Duration(o.MaxWaitMs) * time.Millisecond
+	}
+	return defaultMaxWait
+}
+
+// 导航结果
+type loadResult struct {
+	Response  *network.Response // 主文档的响应，同文档内导航时为 nil
+	RequestID network.RequestID // 主文档请求的 ID，用于读取响应正文
+	TimedOut  bool              // 到达等待上限时页面仍未满足判定条件
+}
+
+// 记录导航期间的生命周期事件、主文档响应与进行中的请求
+type loadTracker struct {
+	mu        sync.Mutex
+	maxIdle   int
+	lifecycle map[string]bool // loaderID + "/" + 事件名
+	responses map[cdp.LoaderID]*network.EventResponseReceived
+	inflight  map[network.RequestID]bool
+	idleSince time.Time // 进行中的请求数降到 maxIdle 以下的时刻，未空闲时为零值
+}
+
+func newLoadTracker(maxIdle int) *loadTracker {
+	return &loadTracker{
+		maxIdle:   maxIdle,
+		lifecycle: make(map[string]bool),
+		responses: make(map[cdp.LoaderID]*network.EventResponseReceived),
+		inflight:  make(map[network.RequestID]bool),
+		idleSince: time.Now(),
+	}
+}
+
+func (t *loadTracker) handleEvent(ev any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch ev := ev.(type) {
+	case *page.EventLifecycleEvent:
+		t.lifecycle[string(ev.LoaderID)+"/"+ev.Name] = true
+	case *network.EventResponseReceived:
+		if ev.Type == network.ResourceTypeDocument {
+			t.responses[ev.LoaderID] = ev
+		}
+	case *network.EventRequestWillBeSent:
+		// 跳转沿用同一个 RequestID，不重复计数
+		t.inflight[ev.RequestID] = true
+		if len(t.inflight) > t.maxIdle {
+			t.idleSince = time.Time{}
+		}
+	case *network.EventLoadingFinished:
+		t.finish(ev.RequestID)
+	case *network.EventLoadingFailed:
+		t.finish(ev.RequestID)
+	}
+}
+
+func (t *loadTracker) finish(id network.RequestID) {
+	if !t.inflight[id] {
+		return
+	}
+	delete(t.inflight, id)
+	if len(t.inflight) <= t.maxIdle && t.idleSince.IsZero() {
+		t.idleSince = time.Now()
+	}
+}
+
+func (t *loadTracker) reached(loaderID cdp.LoaderID, event string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.lifecycle[string(loaderID)+"/"+event]
+}
+
+func (t *loadTracker) idleFor() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.idleSince.IsZero() {
+		return 0
+	}
+	return time.Since(t.idleSince)
+}
+
+func (t *loadTracker) response(loaderID cdp.LoaderID) *network.EventResponseReceived {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.responses[loaderID]
+}
+
+// 导航到 url 并按 opts 等待页面加载，等待时长不超过 opts.MaxWaitMs
+func navigateAndWait(ctx context.Context, url string, opts loadOptions) (*loadResult, error) {
+	start := time.Now()
+	tracker := newLoadTracker(opts.idleConnections())
+
+	// 监听器随 listenCtx 取消而移除
+	listenCtx, cancelListen := context.WithCancel(ctx)
+	defer cancelListen()
+	chromedp.ListenTarget(listenCtx, tracker.handleEvent)
+
+	var loaderID cdp.LoaderID
+	err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		_, id, errorText, _, err := page.Navigate(url).Do(ctx)
+		switch {
+		case err != nil:
+			return err
+		case errorText != "":
+			return fmt.Errorf("page load error %s", errorText)
+		}
+		loaderID = id
+		return nil
+	}))
+	if err != nil {
+		return nil, wrapError(err, codeNavTimeout, "打开页面失败")
+	}
+	result := &loadResult{}
+	if loaderID == "" {
+		// 同文档内导航（如只改变片段），没有新的加载过程
+		return result, nil
+	}
+
+	waitCtx, cancelWait := context.WithDeadline(ctx, start.Add(opts.maxWait()))
+	defer cancelWait()
+	err = waitForLoad(waitCtx, tracker, loaderID, opts)
+	if ev := tracker.response(loaderID); ev != nil {
+		result.Response, result.RequestID = ev.Response, ev.RequestID
+	}
+	switch {
+	case err == nil:
+	case ctx.Err() != nil:
+		return result, wrapError(ctx.Err(), codeNavTimeout, "等待页面加载被中断")
+	case waitCtx.Err() == nil:
+		return result, wrapError(err, codeSelectorTimeout, "等待页面加载失败")
+	case opts.WaitUntil == waitSelector:
+		return result, newError(codeSelectorTimeout, fmt.Sprintf("等待元素 %s 超时", opts.Selector), err)
+	case opts.WaitUntil == waitPredicate:
+		return result, newError(codeSelectorTimeout, "等待 JS 条件为真超时", err)
+	default:
+		log.Printf("等待页面加载到达上限 %v，按已加载的内容继续: %s", opts.maxWait(), url)
+		result.TimedOut = true
+	}
+	return result, nil
+}
+
+// 按 opts.WaitUntil 等待加载完成，超时由 ctx 控制
+func waitForLoad(ctx context.Context, tracker *loadTracker, loaderID cdp.LoaderID, opts loadOptions) error {
+	ticker := time.NewTicker(loadPollInterval)
+	defer ticker.Stop()
+	for {
+		var done bool
+		switch opts.WaitUntil {
+		case waitLoad:
+			done = tracker.reached(loaderID, "load")
+		case waitNetworkIdle:
+			done = tracker.reached(loaderID, "DOMContentLoaded") && tracker.idleFor() >= opts.idleWindow()
+		default:
+			// 等待元素或 JS 条件前先等文档解析完成
+			done = tracker.reached(loaderID, "DOMContentLoaded")
+		}
+		if done {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	switch opts.WaitUntil {
+	case waitSelector:
+		return chromedp.Run(ctx, chromedp.WaitVisible(opts.Selector, chromedp.ByQuery))
+	case waitPredicate:
+		return chromedp.Run(ctx, chromedp.Poll(opts.Predicate, nil, chromedp.WithPollingTimeout(0)))
+	}
+	return nil
+}
+
+// 导出可配置的访问功能：按 optionsJSON 中的 fetch 选择 HTTP 或浏览器，按 wait_until 等判定页面加载完成，
+// 按 extract 与 format 提取内容
+//
+// optionsJSON 可为空，此时先用 HTTP 获取，页面需要执行 JS 时改用浏览器并等待网络空闲，返回整个页面的纯文本；
+// extract 为 main 时返回 Markdown 格式的正文，为 links 时 text 为空，outline 中返回标题大纲与链接。
+// 设置 tables 时在 tables 中另外返回每个数据表格，合并的单元格已展开。PDF、JSON、文本与图片按内容类型返回文本或图片信息。
+// 设置 max_chars 或 max_tokens_estimate 时分块返回，以 next_cursor 作为 cursor 取下一块，此时 url 可为空；
+// metadata、outline 与 tables 只随第一块返回。
+// 返回结果信封 {ok, data, error}，data 与 VisitJSON 相同，调用方须以 FreeString 释放。
+//
+//export VisitWithOptions
+func VisitWithOptions(url *C.char, optionsJSON *C.char) *C.char {
+	goURL := C.GoString(url)
+	opts, err := parseVisitOptions(C.GoString(optionsJSON))
+	if err != nil {
+		return toCResponse(nil, err)
+	}
+	if opts.Cursor != "" {
+		return toCResponse(visitChunk(opts.Cursor))
+	}
+	if strings.TrimSpace(goURL) == "" {
+		return toCResponse(nil, newError(codeInvalidArgument, "URL 为空", nil))
+	}
+	result, err := runVisit(goURL, opts)
+	if err != nil {
+		log.Printf("访问功能执行失败: %v", err)
+	}
+	return toCResponse(result, err)
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// 缓存的渲染结果的保留时长，过期后须重新访问
+	renderRetention = 10 * time.Minute
+	// 最多缓存的渲染结果数，超出时丢弃最早的
+	maxCachedRenders = 32
+	// 估算 token 数时每个 token 对应的非中日韩字符数
+	charsPerToken = 4
+)
+
+// 分页访问时缓存的完整渲染结果
+type cachedRender struct {
+	result    visitResult // 不含正文
+	chunks    []string
+	createdAt time.Time
+}
+
+// 渲染结果缓存
+var (
+	rendersMu sync.Mutex
+	renders   = make(map[string]*cachedRender)
+	renderSeq atomic.Int64
+)
+
+// 清理过期与超出数量的渲染结果，调用方须持有 rendersMu
+func pruneRendersLocked() {
+	var oldestID string
+	var oldest time.Time
+	for id, r := range renders {
+		if time.Since(r.createdAt) > renderRetention {
+			delete(renders, id)
+			continue
+		}
+		if oldestID == "" || r.createdAt.Before(oldest) {
+			oldestID, oldest = id, r.createdAt
+		}
+	}
+	if len(renders) >= maxCachedRenders {
+		delete(renders, oldestID)
+	}
+}
+
+// 粗略估算文本的 token 数：中日韩字符各算一个，其余字符每 4 个算一个
+func estimateTokens(s string) int {
+	var cjk, other int
+	for _, r := range s {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+charsPerToken-1)/charsPerToken
+}
+
+// 每块的长度上限
+type chunkBudget struct {
+	maxChars  int
+	maxTokens int
+}
+
+func (b chunkBudget) fits(s string) bool {
+	if b.maxChars > 0 && utf8.RuneCountInString(s) > b.maxChars {
+		return false
+	}
+	return b.maxTokens <= 0 || estimateTokens(s) <= b.maxTokens
+}
+
+// 按段落与标题边界把文本切分为不超过预算的块；单段超出预算时依次按行、句子、空白与字符切分
+func splitChunks(text string, sep string, budget chunkBudget) []string {
+	var chunks []string
+	var current string
+	for _, block := range splitBlocks(text, sep, budget) {
+		if current == "" {
+			current = block
+			continue
+		}
+		joined := current + sep + block
+		// 标题尽量作为新块的开头，当前块已用过半预算时在标题前切分
+		if !budget.fits(joined) || (isHeading(block) && !budget.fits(current+sep+current)) {
+			chunks = append(chunks, current)
+			current = block
+			continue
+		}
+		current = joined
+	}
+	if current != "" {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+func isHeading(block string) bool {
+	return strings.HasPrefix(block, "#")
+}
+
+// 把文本切分为各自不超过预算的段落
+func splitBlocks(text, sep string, budget chunkBudget) []string {
+	var blocks []string
+	for _, block := range strings.Split(text, sep) {
+		if strings.TrimSpace(block) == "" {
+			continue
+		}
+		if budget.fits(block) {
+			blocks = append(blocks, block)
+			continue
+		}
+		if sep != "\n" {
+			blocks = append(blocks, splitBlocks(block, "\n", budget)...)
+			continue
+		}
+		blocks = append(blocks, splitLine(block, budget, sentenceEndRe, whitespaceRe)...)
+	}
+	return blocks
+}
+
+var (
+	// 句末标点及其后的引号、括号与空白
+	sentenceEndRe = regexp.MustCompile(`[.!?;。！？；…]+["'”’)）」』]*\s*`)
+	whitespaceRe  = regexp.MustCompile(`\s+`)
+)
+
+// 在 boundaries[0] 的各处匹配之后切分过长的一行，把相邻的片段合并到预算内；
+// 仍超出预算的片段按后续的边界切分，最后按字符切分
+func splitLine(line string, budget chunkBudget, boundaries ...*regexp.Regexp) []string {
+	if len(boundaries) == 0 {
+		return splitRunes(line, budget)
+	}
+	var parts []string
+	var current string
+	flush := func() {
+		if s := strings.TrimSpace(current); s != "" {
+			parts = append(parts, s)
+		}
+		current = ""
+	}
+	for _, piece := range splitAfter(line, boundaries[0]) {
+		if budget.fits(current + piece) {
+			current += piece
+			continue
+		}
+		flush()
+		if budget.fits(piece) {
+			current = piece
+			continue
+		}
+		parts = append(parts, splitLine(piece, budget, boundaries[1:]...)...)
+	}
+	flush()
+	return parts
+}
+
+// 在 re 的每处匹配之后切分 s，片段保留匹配的内容
+func splitAfter(s string, re *regexp.Regexp) []string {
+	var pieces []string
+	start := 0
+	for _, loc := range re.FindAllStringIndex(s, -1) {
+		if loc[1] > start {
+			pieces = append(pieces, s[start:loc[1]])
+			start = loc[1]
+		}
+	}
+	if start < len(s) {
+		pieces = append(pieces, s[start:])
+	}
+	return pieces
+}
+
+// 按字符切分一行过长的文本
+func splitRunes(line string, budget chunkBudget) []string {
+	var parts []string
+	runes := []rune(line)
+	for len(runes) > 0 {
+		// 二分查找不超过预算的最长前缀，至少取一个字符
+		lo, hi := 1, len(runes)
+		for lo < hi {
+			mid := (lo + hi + 1) / 2
+			if budget.fits(string(runes[:mid])) {
+				lo = mid
+			} else {
+				hi = mid - 1
+			}
+		}
+		parts = append(parts, string(runes[:lo]))
+		runes = runes[lo:]
+	}
+	return parts
+}
+
+// 按 opts 中的长度预算分页：超出预算时缓存完整结果并返回第一块
+func paginateVisit(result *visitResult, opts visitOptions) *visitResult {
+	budget := chunkBudget{maxChars: opts.MaxChars, maxTokens: opts.MaxTokensEstimate}
+	if result == nil || (budget.maxChars <= 0 && budget.maxTokens <= 0) {
+		return result
+	}
+	result.TotalChars = utf8.RuneCountInString(result.Text)
+	if budget.fits(result.Text) {
+		result.Chunk, result.TotalChunks = 1, 1
+		return result
+	}
+
+	// Markdown 以空行分段，纯文本以换行分段
+	sep := "\n"
+	if opts.Format == formatMarkdown {
+		sep = "\n\n"
+	}
+	render := &cachedRender{
+		result:    *result,
+		chunks:    splitChunks(result.Text, sep, budget),
+		createdAt: time.Now(),
+	}
+	render.result.Text = ""
+	id := fmt.Sprintf("render-%d", renderSeq.Add(1))
+
+	rendersMu.Lock()
+	pruneRendersLocked()
+	renders[id] = render
+	rendersMu.Unlock()
+
+	return render.chunk(id, 0)
+}
+
+// 返回第 index 块（从 0 开始）；元数据、大纲与表格只随第一块返回，不在每块中重复
+func (r *cachedRender) chunk(id string, index int) *visitResult {
+	result := r.result
+	if index > 0 {
+		result.Metadata, result.Outline, result.Tables = nil, nil, nil
+	}
+	result.Text = r.chunks[index]
+	result.Chunk = index + 1
+	result.TotalChunks = len(r.chunks)
+	if index+1 < len(r.chunks) {
+		result.NextCursor = id + ":" + strconv.Itoa(index+1)
+	}
+	return &result
+}
+
+// 按游标从缓存中取出一块，不重新加载页面
+func visitChunk(cursor string) (*visitResult, error) {
+	id, rawIndex, ok := strings.Cut(cursor, ":")
+	index, err := strconv.Atoi(rawIndex)
+	if !ok || err != nil || index < 0 {
+		return nil, newError(codeInvalidArgument, fmt.Sprintf("游标格式不正确: %s", cursor), nil)
+	}
+
+	rendersMu.Lock()
+	defer rendersMu.Unlock()
+	r, ok := renders[id]
+	if !ok || time.Since(r.createdAt) > renderRetention {
+		return nil, newError(codeNotFound, "游标对应的页面内容已过期，请重新访问", nil)
+	}
+	if index >= len(r.chunks) {
+		return nil, newError(codeInvalidArgument, fmt.Sprintf("游标超出范围，共 %d 块", len(r.chunks)), nil)
+	}
+	return r.chunk(id, index), nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode"
+)
+
+// 去掉空白后比较，分块时行内切分处的空白会被去掉
+func stripSpace(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func TestSplitChunks(t *testing.T) {
+	long := strings.Repeat("The quick brown fox jumps over the lazy dog. ", 20)
+	tests := []struct {
+		name   string
+		text   string
+		sep    string
+		budget chunkBudget
+	}{
+		{"paragraphs", strings.Repeat("Lorem ipsum dolor sit amet.\n\n", 30), "\n\n", chunkBudget{maxChars: 100}},
+		{"long line split at sentences", long, "\n", chunkBudget{maxChars: 120}},
+		{"long word split at characters", strings.Repeat("x", 500), "\n", chunkBudget{maxChars: 64}},
+		{"CJK by tokens", strings.Repeat("这是一个用于测试分块的中文句子。", 40), "\n\n", chunkBudget{maxTokens: 50}},
+		{"markdown with both budgets", "# Title\n\n" + long + "\n\n## Section\n\n- a\n- b\n\n" + long, "\n\n", chunkBudget{maxChars: 300, maxTokens: 40}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := splitChunks(tt.text, tt.sep, tt.budget)
+			if len(chunks) < 2 {
+				t.Fatalf("got %d chunks, want the text to be split", len(chunks))
+			}
+			for i, c := range chunks {
+				if !tt.budget.fits(c) {
+					t.Errorf("chunk %d exceeds the budget (%d chars, ~%d tokens)", i, len([]rune(c)), estimateTokens(c))
+				}
+				if strings.TrimSpace(c) == "" {
+					t.Errorf("chunk %d is empty", i)
+				}
+			}
+			if got, want := stripSpace(strings.Join(chunks, tt.sep)), stripSpace(tt.text); got != want {
+				t.Errorf("rejoined chunks lost text:\ngot  %q\nwant %q", got, want)
+			}
+		})
+	}
+}
+
+// 按段落切分时原样保留段落，以分隔符重新连接即得原文
+func TestSplitChunksKeepsParagraphs(t *testing.T) {
+	var paragraphs []string
+	for i := 0; i < 20; i++ {
+		paragraphs = append(paragraphs, strings.Repeat(string(rune('a'+i)), 10+i*3))
+	}
+	text := strings.Join(paragraphs, "\n\n")
+	chunks := splitChunks(text, "\n\n", chunkBudget{maxChars: 80})
+	if got := strings.Join(chunks, "\n\n"); got != text {
+		t.Errorf("rejoined = %q, want %q", got, text)
+	}
+}
+
+func TestSplitChunksBreaksBeforeHeading(t *testing.T) {
+	intro := strings.Repeat("a", 60)
+	budget := chunkBudget{maxChars: 100}
+
+	// 当前块已用过半预算，标题开始新的一块
+	chunks := splitChunks(intro+"\n\n# Heading\n\nbody", "\n\n", budget)
+	if len(chunks) != 2 || chunks[0] != intro || !strings.HasPrefix(chunks[1], "# Heading") {
+		t.Errorf("chunks = %q, want a break before the heading", chunks)
+	}
+
+	// 当前块较短时标题留在块中
+	chunks = splitChunks("short\n\n# Heading\n\nbody", "\n\n", budget)
+	if len(chunks) != 1 {
+		t.Errorf("chunks = %q, want a single chunk", chunks)
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"abcd", 1},
+		{"abcde", 2},
+		{"中文", 2},
+		{"中文ab", 3},
+		{"こんにちは", 5},
+		{"カタカナ", 4},
+		{"한국어", 3},
+		{"Go 语言", 3},
+	}
+	for _, tt := range tests {
+		if got := estimateTokens(tt.text); got != tt.want {
+			t.Errorf("estimateTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestVisitChunk(t *testing.T) {
+	text := strings.Repeat("Paragraph of text.\n\n", 10)
+	first := paginateVisit(&visitResult{Title: "t", Text: text, Metadata: &pageMetadata{}},
+		visitOptions{Format: formatMarkdown, MaxChars: 50})
+	if first.Chunk != 1 || first.TotalChunks < 2 || first.NextCursor == "" || first.Metadata == nil {
+		t.Fatalf("first chunk = %+v, want chunk 1 of several with metadata and a cursor", first)
+	}
+
+	// 沿游标取出全部块
+	parts := []string{first.Text}
+	cursor := first.NextCursor
+	for cursor != "" {
+		r, err := visitChunk(cursor)
+		if err != nil {
+			t.Fatalf("visitChunk(%q): %v", cursor, err)
+		}
+		if r.Metadata != nil || r.Title != "t" {
+			t.Errorf("chunk %d: metadata = %v, title = %q; want no metadata and the page title", r.Chunk, r.Metadata, r.Title)
+		}
+		parts = append(parts, r.Text)
+		cursor = r.NextCursor
+	}
+	if len(parts) != first.TotalChunks {
+		t.Errorf("followed %d chunks, want %d", len(parts), first.TotalChunks)
+	}
+	if got := strings.Join(parts, "\n\n"); got != strings.TrimSuffix(text, "\n\n") {
+		t.Errorf("rejoined = %q, want %q", got, text)
+	}
+
+	id, _, _ := strings.Cut(first.NextCursor, ":")
+	tests := []struct {
+		cursor string
+		code   errorCode
+	}{
+		{"no-colon", codeInvalidArgument},
+		{id + ":abc", codeInvalidArgument},
+		{id + ":-1", codeInvalidArgument},
+		{id + ":99", codeInvalidArgument},
+		{"render-unknown:1", codeNotFound},
+	}
+	for _, tt := range tests {
+		if _, err := visitChunk(tt.cursor); classifyError(err) != tt.code {
+			t.Errorf("visitChunk(%q) error = %v, want %v", tt.cursor, err, tt.code)
+		}
+	}
+
+	// 过期的渲染结果
+	rendersMu.Lock()
+	renders[id].createdAt = time.Now().Add(-renderRetention - time.Second)
+	rendersMu.Unlock()
+	if _, err := visitChunk(first.NextCursor); classifyError(err) != codeNotFound {
+		t.Errorf("expired cursor error = %v, want %v", err, codeNotFound)
+	}
+}
+
+// 未超出预算时不分块，也不缓存
+func TestPaginateVisitFits(t *testing.T) {
+	r := paginateVisit(&visitResult{Text: "短文本"}, visitOptions{MaxTokensEstimate: 10})
+	if r.Chunk != 1 || r.TotalChunks != 1 || r.NextCursor != "" || r.TotalChars != 3 {
+		t.Errorf("result = %+v, want a single chunk of 3 chars", r)
+	}
+}
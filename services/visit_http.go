@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/brotli"
+	"golang.org/x/net/html/charset"
+)
+
+// 获取页面的方式
+const (
+	fetchAuto    = "auto"    // 先用 HTTP 获取，页面需要执行 JS 时改用浏览器
+	fetchHTTP    = "http"    // 只用 HTTP 获取，不启动浏览器
+	fetchBrowser = "browser" // 用浏览器打开
+)
+
+const (
+	// 页面正文的最大字节数
+	maxFetchBody = 10 << 20
+	// 最多跟随的跳转次数
+	maxFetchRedirects = 10
+	// 正文少于此字符数时，才把 noscript 中的提示或 SPA 根节点视为需要执行 JS
+	minStaticTextChars = 200
+)
+
+var (
+	// 页面要求启用 JavaScript 的提示
+	jsRequiredRe = regexp.MustCompile(`(?i)enable javascript|javascript is (?:disabled|required)|(?:启用|开启|需要)\s*javascript`)
+	// 常见前端框架的挂载点
+	spaRootSelector = "#root, #app, #__next, #__nuxt, #___gatsby, [data-reactroot], [ng-app], app-root"
+)
+
+// 超时由调用方的 context 控制
+var fetchClient = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxFetchRedirects {
+			return fmt.Errorf("跳转次数超过 %d", maxFetchRedirects)
+		}
+		return nil
+	},
+}
+
+// 通过 HTTP 获取页面并提取内容；needsBrowser 不为空时说明页面需要浏览器才能正确显示的原因，
+// 此时 result 仍包含 HTTP 获取到的内容
+func fetchPage(ctx context.Context, url string, opts visitOptions) (result *visitResult, needsBrowser string, err error) {
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, normalizeURL(url), nil)
+	if err != nil {
+		return nil, "", newError(codeInvalidArgument, "URL 不合法", err)
+	}
+	req.Header.Set("User-Agent", resolveUserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/json;q=0.9,*/*;q=0.8")
+	// 手动设置后 Transport 不再自动解压，由 decodeBody 处理
+	req.Header.Set("Accept-Encoding", "gzip, br")
+
+	resp, err := fetchClient.Do(req)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return nil, "", wrapError(err, codeNavTimeout, "请求页面超时")
+		}
+		return nil, "", newError(codeNavFailed, "请求页面失败", err)
+	}
+	defer resp.Body.Close()
+
+	result = &visitResult{
+		FinalURL:    resp.Request.URL.String(),
+		StatusCode:  resp.StatusCode,
+		FetchedWith: fetchHTTP,
+	}
+	emitEvent(ctx, eventPageLoaded, map[string]any{"url": url, "status_code": result.StatusCode})
+
+	body, err := decodeBody(resp)
+	if err != nil {
+		return result, "", wrapError(err, codeNavTimeout, "读取页面失败")
+	}
+	contentType := resp.Header.Get("Content-Type")
+	result.ContentType = detectMediaType(contentType, body)
+
+	if !isHTMLType(result.ContentType) {
+		// PDF、JSON、文本与图片在浏览器中也只能得到同样的内容，不再改用浏览器
+		if err := renderNonHTML(result, result.ContentType, contentType, body); err != nil {
+			return result, "", err
+		}
+		result.LoadMs = time.Since(start).Milliseconds()
+		return paginateVisit(result, opts), "", nil
+	}
+
+	switch resp.StatusCode {
+	case http.StatusForbidden, http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		// 常见于拦截非浏览器请求的站点
+		needsBrowser = fmt.Sprintf("HTTP %d", resp.StatusCode)
+	}
+	reader, err := charset.NewReader(bytes.NewReader(body), contentType)
+	if err != nil {
+		return result, needsBrowser, newError(codeNavFailed, "无法识别页面编码", err)
+	}
+	doc, err := goquery.NewDocumentFromReader(reader)
+	if err != nil {
+		return result, needsBrowser, newError(codeNavFailed, "解析页面 HTML 失败", err)
+	}
+	result.Kind = kindHTML
+	result.Title = strings.TrimSpace(doc.Find("title").First().Text())
+	if reason := jsRenderedReason(doc); reason != "" && needsBrowser == "" {
+		needsBrowser = reason
+	}
+	// 提取正文会修改文档，先读取元数据与表格
+	result.Metadata = documentMetadata(doc, resp.Request.URL)
+	if opts.Tables != tablesNone {
+		if result.Tables, err = documentTables(doc, opts.Tables); err != nil {
+			return result, needsBrowser, err
+		}
+	}
+	if opts.Extract == extractLinks {
+		result.Outline = documentOutline(doc, resp.Request.URL)
+	} else {
+		result.Text = extractDocument(doc, resp.Request.URL, contentOptions{Extract: opts.Extract, Format: opts.Format})
+	}
+
+	result.LoadMs = time.Since(start).Milliseconds()
+	return paginateVisit(result, opts), needsBrowser, nil
+}
+
+// 按 Content-Encoding 解压响应正文
+func decodeBody(resp *http.Response) ([]byte, error) {
+	var reader io.Reader = resp.Body
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = gz
+	case "br":
+		reader = brotli.NewReader(resp.Body)
+	}
+	return io.ReadAll(io.LimitReader(reader, maxFetchBody))
+}
+
+// 判断页面是否要执行 JS 才能显示内容，返回原因；不需要时返回空字符串
+func jsRenderedReason(doc *goquery.Document) string {
+	body := doc.Find("body").First().Clone()
+	noscript := body.Find("noscript").Text()
+	body.Find("script, style, noscript, template").Remove()
+	text := strings.Join(strings.Fields(body.Text()), " ")
+	length := utf8.RuneCountInString(text)
+
+	switch {
+	case length == 0:
+		return "页面正文为空"
+	case jsRequiredRe.MatchString(text):
+		return "页面提示须启用 JavaScript"
+	case length < minStaticTextChars && jsRequiredRe.MatchString(noscript):
+		return "页面提示须启用 JavaScript"
+	case length < minStaticTextChars && doc.Find(spaRootSelector).Length() > 0:
+		return "页面是尚未渲染的单页应用"
+	}
+	return ""
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/gif" // 注册图片解码器，用于读取尺寸
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"github.com/ledongthuc/pdf"
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+	"golang.org/x/net/html/charset"
+)
+
+// 页面内容的类型
+const (
+	kindHTML  = "html"
+	kindJSON  = "json"
+	kindText  = "text"
+	kindPDF   = "pdf"
+	kindImage = "image"
+)
+
+// 最多提取文本的 PDF 页数
+const maxPDFPages = 500
+
+// 图片信息
+type imageInfo struct {
+	Format string `json:"format"` // png、jpeg、gif、webp、bmp 或 tiff
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Bytes  int    `json:"bytes"`
+}
+
+// 确定响应的内容类型；Content-Type 缺失或为通用二进制类型时按内容嗅探
+func detectMediaType(contentType string, body []byte) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	mediaType = strings.ToLower(mediaType)
+	if mediaType == "" || mediaType == "application/octet-stream" || mediaType == "binary/octet-stream" {
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(body))
+	}
+	return mediaType
+}
+
+func isHTMLType(mediaType string) bool {
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+func isJSONType(mediaType string) bool {
+	return mediaType == "application/json" || mediaType == "text/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// 可以按文本返回的类型
+func isTextType(mediaType string) bool {
+	switch mediaType {
+	case "application/xml", "application/javascript", "application/x-javascript", "application/ecmascript",
+		"application/x-yaml", "application/yaml", "application/toml", "application/x-sh":
+		return true
+	}
+	return strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "+xml")
+}
+
+// 按内容类型渲染非 HTML 的响应，填充 result 的 Kind、Text、Pages 与 Image
+func renderNonHTML(result *visitResult, mediaType, contentType string, body []byte) error {
+	switch {
+	case isJSONType(mediaType):
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, bytes.TrimPrefix(body, []byte("\xef\xbb\xbf")), "", "  "); err == nil {
+			result.Kind = kindJSON
+			result.Text = buf.String()
+			return nil
+		}
+		// 不是合法的 JSON 时按文本返回
+		return renderText(result, contentType, body)
+	case mediaType == "application/pdf":
+		text, pages, title, err := extractPDF(body)
+		if err != nil {
+			return newError(codeUnsupported, "无法提取 PDF 中的文本", err)
+		}
+		result.Kind = kindPDF
+		result.Text = text
+		result.Pages = pages
+		if title != "" {
+			result.Title = title
+		}
+		return nil
+	case strings.HasPrefix(mediaType, "image/") && !strings.HasSuffix(mediaType, "+xml"):
+		config, format, err := image.DecodeConfig(bytes.NewReader(body))
+		if err != nil {
+			return newError(codeUnsupported, fmt.Sprintf("无法识别图片格式 %s", mediaType), err)
+		}
+		result.Kind = kindImage
+		result.Image = &imageInfo{Format: format, Width: config.Width, Height: config.Height, Bytes: len(body)}
+		result.Text = fmt.Sprintf("%s 图片，%d×%d，%d 字节", format, config.Width, config.Height, len(body))
+		return nil
+	case isTextType(mediaType):
+		return renderText(result, contentType, body)
+	}
+	return newError(codeUnsupported, fmt.Sprintf("不支持的内容类型 %s", mediaType), nil)
+}
+
+// 按 Content-Type 中的编码或内容嗅探解码文本
+func renderText(result *visitResult, contentType string, body []byte) error {
+	reader, err := charset.NewReader(bytes.NewReader(body), contentType)
+	if err != nil {
+		return newError(codeNavFailed, "无法识别文本编码", err)
+	}
+	text, err := io.ReadAll(reader)
+	if err != nil {
+		return newError(codeNavFailed, "读取文本失败", err)
+	}
+	result.Kind = kindText
+	result.Text = string(text)
+	return nil
+}
+
+// 提取 PDF 的文本、页数与标题；解析库遇到损坏的文件时会 panic，这里转为错误
+func extractPDF(body []byte) (text string, pages int, title string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("解析 PDF 失败: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return "", 0, "", err
+	}
+	pages = reader.NumPage()
+	title = strings.TrimSpace(reader.Trailer().Key("Info").Key("Title").Text())
+
+	var b strings.Builder
+	for i := 1; i <= pages && i <= maxPDFPages; i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+		// 不同页面的同名字体可能不同，每页单独解析字体
+		pageText, err := page.GetPlainText(nil)
+		if err != nil {
+			return "", pages, title, err
+		}
+		if pageText = strings.TrimSpace(pageText); pageText != "" {
+			if b.Len() > 0 {
+				b.WriteString("\n\n")
+			}
+			b.WriteString(pageText)
+		}
+	}
+	return b.String(), pages, title, nil
+}
+
+// 用 HEAD 请求探测地址的内容类型
+func probeMediaType(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, normalizeURL(url), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", resolveUserAgent)
+	resp, err := fetchClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return strings.ToLower(mediaType), nil
+}
+
+// 读取浏览器中主文档的响应正文，按内容类型渲染
+func renderBrowserResponse(ctx context.Context, result *visitResult, load *loadResult) error {
+	var body []byte
+	err := chromedp.Run(ctx,
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			body, err = network.GetResponseBody(load.RequestID).Do(ctx)
+			return err
+		}),
+		chromedp.Location(&result.FinalURL),
+		chromedp.Title(&result.Title),
+	)
+	if err != nil {
+		return wrapError(err, codeNavTimeout, "读取响应正文失败")
+	}
+
+	// 响应头保留了原始大小写，Content-Type 中可能带有编码
+	contentType := load.Response.MimeType
+	for name, value := range load.Response.Headers {
+		if v, ok := value.(string); ok && strings.EqualFold(name, "Content-Type") {
+			contentType = v
+		}
+	}
+	result.ContentType = detectMediaType(contentType, body)
+	return renderNonHTML(result, result.ContentType, contentType, body)
+}
@@ -1,5 +1,9 @@
 
 
+use std::path::PathBuf;
+use std::sync::{Arc, Mutex};
+use std::time::Duration;
+
 use async_trait::async_trait;
 use serde_json::json;
 use tracing::info;
@@ -10,6 +14,69 @@ use super::{schema_object, Tool, ToolResult, servicor};
 pub struct BrowserTool {
     default_timeout_secs: u64,
     browser_executable_path: Option<String>,
+    session: Arc<BrowserSession>,
+}
+
+// 工具持有的浏览器会话；FFI 调用会阻塞，须在 spawn_blocking 的线程中使用
+struct BrowserSession {
+    browser_executable_path: Option<String>,
+    profile_dir: PathBuf,
+    session_id: Mutex<Option<String>>,
+}
+
+impl BrowserSession {
+    // 返回已打开的会话，没有则以持久化的用户数据目录启动一个；
+    // 打开时的 timeout_secs 只是会话的默认超时，每条命令另以自己的 timeout_secs 执行
+    fn ensure(&self, timeout_secs: u64) -> Result<String, String> {
+        let mut guard = self.session_id.lock().unwrap();
+        if let Some(id) = guard.as_ref() {
+            return Ok(id.clone());
+        }
+        let id = servicor::session_open(&json!({
+            "exec_path": self.browser_executable_path.clone().unwrap_or_default(),
+            "user_data_dir": self.profile_dir.to_string_lossy(),
+            "timeout_secs": timeout_secs,
+        }))?;
+        *guard = Some(id.clone());
+        Ok(id)
+    }
+
+    // 在会话中执行一条命令；会话已关闭（如空闲超时）时清除记录，下次调用重新打开
+    fn exec(
+        &self,
+        command: &str,
+        name: &str,
+        timeout_secs: u64,
+    ) -> Result<serde_json::Value, String> {
+        let session_id = self
+            .ensure(timeout_secs)
+            .map_err(|e| format!("Failed to start browser: {e}"))?;
+        let result = servicor::browser_exec(&session_id, command, timeout_secs);
+        let gone = match &result {
+            _ if name == "close" => true,
+            // NOT_FOUND 也用于元素、标签页等不存在，用一条只读命令确认会话本身是否还在
+            Err(e) if e.name == "NOT_FOUND" => matches!(
+                servicor::browser_exec(&session_id, "get url", timeout_secs),
+                Err(e) if e.name == "NOT_FOUND"
+            ),
+            _ => false,
+        };
+        if gone {
+            let mut guard = self.session_id.lock().unwrap();
+            if guard.as_deref() == Some(session_id.as_str()) {
+                *guard = None;
+            }
+        }
+        result.map_err(|e| format!("Browser command `{name}` failed: {e}"))
+    }
+}
+
+impl Drop for BrowserSession {
+    fn drop(&mut self) {
+        if let Some(id) = self.session_id.get_mut().ok().and_then(|id| id.take()) {
+            let _ = servicor::session_close(&id);
+        }
+    }
 }
 
 fn split_browser_command(command: &str) -> Result<Vec<String>, String> {
@@ -68,10 +135,15 @@ fn split_browser_command(command: &str) -> Result<Vec<String>, String> {
 }
 
 impl BrowserTool {
-    pub fn new(_data_dir: &str, browser_executable_path: Option<String>) -> Self {
+    pub fn new(data_dir: &str, browser_executable_path: Option<String>) -> Self {
         BrowserTool {
             default_timeout_secs: 30,
-            browser_executable_path,
+            browser_executable_path: browser_executable_path.clone(),
+            session: Arc::new(BrowserSession {
+                browser_executable_path,
+                profile_dir: PathBuf::from(data_dir).join("browser-profile"),
+                session_id: Mutex::new(None),
+            }),
         }
     }
 
@@ -111,7 +183,7 @@ impl Tool for BrowserTool {
             name: "browser".into(),
             description: "Headless browser automation. Browser state (cookies, localStorage, login sessions) persists across calls and across conversations.\n\n 
                 ## Basic workflow\n\
-                1. `open <url>` — navigate to a URL\n\
+                1. `open <url>` — navigate to a URL and wait for the network to go idle (`--wait domcontentloaded|load`, `--max-wait <ms>`)\n\
                 2. `snapshot -i` — get interactive elements with refs (@e1, @e2, ...)\n\
                 3. `click @e1` / `fill @e2 \"text\"` — interact with elements\n\
                 4. `get text @e3` — extract text content\n\
@@ -120,20 +192,20 @@ impl Tool for BrowserTool {
                 **Navigation**: open, back, forward, reload, close\n\
                 **Interaction**: click, dblclick, fill, type, press, hover, select, check, uncheck, upload, drag\n\
                 **Scrolling**: scroll <dir> [px], scrollintoview <sel>\n\
-                **Data extraction**: get text/html/value/attr/title/url/count/box <sel>\n\
+                **Data extraction**: get text/html/value/attr/title/url/count/box <sel>, get table <sel> [markdown|csv|json]\n\
                 **State checks**: is visible/enabled/checked <sel>\n\
                 **Snapshot**: snapshot (-i for interactive only, -c for compact)\n\
-                **Screenshot/PDF**: screenshot [path] (--full for full page), pdf <path>\n\
+                **Screenshot/PDF**: screenshot [path] (--full for full page, --selector <sel>, --format png|jpeg, --quality <n>), pdf [path] (--paper a4, --landscape, --margin 1cm, --background); without a path the data comes back as base64\n\
                 **JavaScript**: eval <js>\n\
-                **Cookies**: cookies, cookies set <name> <val>, cookies clear\n\
-                **Storage**: storage local [key], storage local set <k> <v>, storage local clear (same for session)\n\
+                **Cookies**: cookies [domain], cookies set <name> <val> [--domain <d>], cookies clear [domain], cookies export <path> [--format netscape|json], cookies import <path> (Netscape cookies.txt or JSON)\n\
+                **Storage**: storage local [key], storage local set <k> <v>, storage local remove <k>, storage local clear (same for session)\n\
                 **Tabs**: tab, tab new [url], tab <n>, tab close [n]\n\
                 **Frames**: frame <sel>, frame main\n\
                 **Dialogs**: dialog accept [text], dialog dismiss\n\
                 **Viewport**: set viewport <w> <h>, set device <name>, set media dark/light\n\
                 **Network**: network route <url> [--abort|--body <json>], network requests\n\
                 **Wait**: wait <sel|ms|--text|--url|--load|--fn>\n\
-                **Auth state**: state save <path>, state load <path>\n\
+                **Auth state**: state save <path>, state load <path> (cookies plus the current page's local/session storage, as JSON)\n\
                 **Semantic find**: find role/text/label/placeholder <value> <action> [input]".into(),
             input_schema: schema_object(
                 json!({
@@ -157,7 +229,7 @@ impl Tool for BrowserTool {
             None => return ToolResult::error("Missing 'command' parameter".into()),
         };
 
-        let _timeout_secs = input
+        let timeout_secs = input
             .get("timeout_secs")
             .and_then(|v| v.as_u64())
             .unwrap_or(self.default_timeout_secs);
@@ -175,26 +247,20 @@ impl Tool for BrowserTool {
         let program = self.get_browser_executable();
         info!("Executing browser command via '{}'", program);
 
-        // Handle different command types using servicor
-        if !command_args.is_empty() {
-            match command_args[0].as_str() {
-                "open" if command_args.len() > 1 => {
-                    // For open command, use servicor's Visit function
-                    let url = command_args[1].clone();
-                    servicor::visit(&url);
-                    ToolResult::success("Visit executed successfully. Results are printed to console.".into())
-                },
-                "close" => {
-                    // For close command, not supported by servicor
-                    return ToolResult::error("Close command not supported.".into());
-                },
-                _ => {
-                    // For other commands, not supported by servicor
-                    return ToolResult::error("This command is not supported by servicor.".into());
-                }
-            }
-        } else {
-            return ToolResult::error("Empty browser command".into());
+        // FFI 调用会阻塞线程，不能在 tokio 的工作线程上执行；超时后命令仍在后台线程中执行，
+        // 直到 Go 侧的单条命令超时使其结束
+        let session = Arc::clone(&self.session);
+        let command = command.to_string();
+        let name = command_args[0].clone();
+        let task = tokio::task::spawn_blocking(move || session.exec(&command, &name, timeout_secs));
+        match tokio::time::timeout(Duration::from_secs(timeout_secs.max(1)), task).await {
+            Ok(Ok(Ok(output))) => ToolResult::success(output.to_string()),
+            Ok(Ok(Err(e))) => ToolResult::error(e),
+            Ok(Err(e)) => ToolResult::error(format!("Browser command task failed: {e}")),
+            Err(_) => ToolResult::error(format!(
+                "Browser command `{}` timed out after {timeout_secs}s",
+                command_args[0]
+            )),
         }
     }
 }
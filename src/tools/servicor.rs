@@ -1,31 +1,194 @@
-use std::ffi::CString;
+use std::ffi::{CStr, CString};
+use std::fmt;
 use std::os::raw::c_char;
 
 // 声明从Go静态库导入的函数
 #[link(name = "servico")]
 extern "C" {
-    fn Search(keyword: *const c_char);
-    fn Visit(url: *const c_char);
+    fn SearchJSON(keyword: *const c_char) -> *mut c_char;
+    fn VisitJSON(url: *const c_char) -> *mut c_char;
+    fn VisitWithOptions(url: *const c_char, optionsJSON: *const c_char) -> *mut c_char;
+    fn ExtractStructured(url: *const c_char, schemaJSON: *const c_char) -> *mut c_char;
+    fn Screenshot(url: *const c_char, optionsJSON: *const c_char) -> *mut c_char;
+    fn PrintPDF(url: *const c_char, optionsJSON: *const c_char) -> *mut c_char;
     fn Download(novelURL: *const c_char);
+    fn SessionOpen(optionsJSON: *const c_char) -> *mut c_char;
+    fn SessionClose(sessionID: *const c_char) -> *mut c_char;
+    fn BrowserExecWithOptions(
+        sessionID: *const c_char,
+        commandLine: *const c_char,
+        optionsJSON: *const c_char,
+    ) -> *mut c_char;
+    fn FreeString(s: *mut c_char);
 }
 
-pub fn search(query: &str) {
-    let c_query = CString::new(query).expect("CString::new failed");
-    unsafe {
-        Search(c_query.as_ptr());
+// 将 Go 侧返回的字符串复制为 Rust String，并交回 Go 侧释放
+unsafe fn take_go_string(ptr: *mut c_char) -> String {
+    if ptr.is_null() {
+        return String::new();
     }
+    let s = CStr::from_ptr(ptr).to_string_lossy().into_owned();
+    FreeString(ptr);
+    s
 }
 
-pub fn visit(url: &str) {
-    let c_url = CString::new(url).expect("CString::new failed");
-    unsafe {
-        Visit(c_url.as_ptr());
+/// 结果信封中的错误；`name` 为错误码名称，如 `NOT_FOUND`，调用方应据此判断而不是匹配错误信息
+#[derive(Debug, Clone, PartialEq)]
+pub struct ServicorError {
+    pub name: String,
+    pub message: String,
+    pub retryable: bool,
+}
+
+impl fmt::Display for ServicorError {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        if self.retryable {
+            write!(f, "{}: {} (retryable)", self.name, self.message)
+        } else {
+            write!(f, "{}: {}", self.name, self.message)
+        }
     }
 }
 
+/// 解析 Go 侧返回的结果信封 `{ok, data, error}`，保留错误码
+pub fn decode_envelope(raw: &str) -> Result<serde_json::Value, ServicorError> {
+    let envelope: serde_json::Value = serde_json::from_str(raw).map_err(|e| ServicorError {
+        name: "INTERNAL".into(),
+        message: format!("invalid servicor response: {e}"),
+        retryable: false,
+    })?;
+    if envelope.get("ok").and_then(|v| v.as_bool()).unwrap_or(false) {
+        return Ok(envelope.get("data").cloned().unwrap_or(serde_json::Value::Null));
+    }
+
+    let error = envelope.get("error");
+    let field = |key: &str| error.and_then(|e| e.get(key));
+    Err(ServicorError {
+        name: field("name").and_then(|v| v.as_str()).unwrap_or("UNKNOWN").into(),
+        message: field("message").and_then(|v| v.as_str()).unwrap_or("unknown error").into(),
+        retryable: field("retryable").and_then(|v| v.as_bool()).unwrap_or(false),
+    })
+}
+
+/// 解析 Go 侧返回的结果信封 `{ok, data, error}`，失败时返回可直接展示给模型的错误文本
+pub fn parse_envelope(raw: &str) -> Result<serde_json::Value, String> {
+    decode_envelope(raw).map_err(|e| e.to_string())
+}
+
+/// 执行搜索，成功时返回 JSON 数组 `[{title, url, resolved_url, snippet, source, date, rank, engine}]`
+pub fn search(query: &str) -> Result<serde_json::Value, String> {
+    let c_query = CString::new(query).expect("CString::new failed");
+    let raw = unsafe { take_go_string(SearchJSON(c_query.as_ptr())) };
+    parse_envelope(&raw)
+}
+
+/// 访问页面，成功时返回 JSON 对象 `{final_url, title, text, status_code, content_type, load_ms, fetched_with, kind, metadata}`
+pub fn visit(url: &str) -> Result<serde_json::Value, String> {
+    let c_url = CString::new(url).expect("CString::new failed");
+    let raw = unsafe { take_go_string(VisitJSON(c_url.as_ptr())) };
+    parse_envelope(&raw)
+}
+
+/// 按选项访问页面；设置 `max_chars` 或 `max_tokens_estimate` 时分块返回，
+/// 以上次返回的 `next_cursor` 作为 `cursor` 取下一块，此时不重新加载页面；
+/// `extract` 为 `links` 时在 `outline` 中返回标题大纲与链接，设置 `tables` 时在 `tables` 中返回表格
+pub fn visit_with_options(url: &str, options: &serde_json::Value) -> Result<serde_json::Value, String> {
+    let c_url = CString::new(url).map_err(|e| format!("INVALID_ARGUMENT: {e}"))?;
+    let c_options = CString::new(options.to_string()).expect("CString::new failed");
+    let raw = unsafe { take_go_string(VisitWithOptions(c_url.as_ptr(), c_options.as_ptr())) };
+    parse_envelope(&raw)
+}
+
+/// 按抽取模式从页面中提取结构化数据，成功时返回 JSON 对象 `{final_url, title, count, records}`；
+/// 模式为 `{root, fields: [{name, selector, source, attribute, regex, type, multiple, fields}]}`
+pub fn extract_structured(url: &str, schema: &serde_json::Value) -> Result<serde_json::Value, String> {
+    let c_url = CString::new(url).map_err(|e| format!("INVALID_ARGUMENT: {e}"))?;
+    let c_schema = CString::new(schema.to_string()).expect("CString::new failed");
+    let raw = unsafe { take_go_string(ExtractStructured(c_url.as_ptr(), c_schema.as_ptr())) };
+    parse_envelope(&raw)
+}
+
+/// 截取页面，成功时返回 JSON 对象 `{path, data, mime_type, bytes, width, height}`；
+/// 选项中没有 `path` 时 `data` 为 base64 编码的图片
+pub fn screenshot(url: &str, options: &serde_json::Value) -> Result<serde_json::Value, String> {
+    let c_url = CString::new(url).map_err(|e| format!("INVALID_ARGUMENT: {e}"))?;
+    let c_options = CString::new(options.to_string()).expect("CString::new failed");
+    let raw = unsafe { take_go_string(Screenshot(c_url.as_ptr(), c_options.as_ptr())) };
+    parse_envelope(&raw)
+}
+
+/// 把页面打印为 PDF，成功时返回 JSON 对象 `{path, data, mime_type, bytes}`
+pub fn print_pdf(url: &str, options: &serde_json::Value) -> Result<serde_json::Value, String> {
+    let c_url = CString::new(url).map_err(|e| format!("INVALID_ARGUMENT: {e}"))?;
+    let c_options = CString::new(options.to_string()).expect("CString::new failed");
+    let raw = unsafe { take_go_string(PrintPDF(c_url.as_ptr(), c_options.as_ptr())) };
+    parse_envelope(&raw)
+}
+
+/// 启动长期存活的浏览器会话，成功时返回会话 ID
+pub fn session_open(options: &serde_json::Value) -> Result<String, String> {
+    let c_options = CString::new(options.to_string()).expect("CString::new failed");
+    let raw = unsafe { take_go_string(SessionOpen(c_options.as_ptr())) };
+    let data = parse_envelope(&raw)?;
+    data.get("session_id")
+        .and_then(|v| v.as_str())
+        .map(str::to_string)
+        .ok_or_else(|| "INTERNAL: missing session_id".to_string())
+}
+
+/// 关闭浏览器会话
+pub fn session_close(session_id: &str) -> Result<(), String> {
+    let c_id = CString::new(session_id).expect("CString::new failed");
+    let raw = unsafe { take_go_string(SessionClose(c_id.as_ptr())) };
+    parse_envelope(&raw).map(|_| ())
+}
+
+/// 在会话中执行一条 browser 命令，成功时返回 JSON 对象 `{command, output, url, title, dialog}`；
+/// `timeout_secs` 为本条命令的超时，0 表示使用会话创建时设置的超时
+pub fn browser_exec(
+    session_id: &str,
+    command: &str,
+    timeout_secs: u64,
+) -> Result<serde_json::Value, ServicorError> {
+    let c_id = CString::new(session_id).expect("CString::new failed");
+    let c_command = CString::new(command).map_err(|e| ServicorError {
+        name: "INVALID_ARGUMENT".into(),
+        message: e.to_string(),
+        retryable: false,
+    })?;
+    let c_options = CString::new(serde_json::json!({ "timeout_secs": timeout_secs }).to_string())
+        .expect("CString::new failed");
+    let raw = unsafe {
+        take_go_string(BrowserExecWithOptions(
+            c_id.as_ptr(),
+            c_command.as_ptr(),
+            c_options.as_ptr(),
+        ))
+    };
+    decode_envelope(&raw)
+}
+
 pub fn download(novel_url: &str) {
     let c_novel_url = CString::new(novel_url).expect("CString::new failed");
     unsafe {
         Download(c_novel_url.as_ptr());
     }
 }
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_decode_envelope_keeps_error_name() {
+        let err = decode_envelope(
+            r#"{"ok":false,"error":{"code":10,"name":"NOT_FOUND","message":"x","retryable":false}}"#,
+        )
+        .unwrap_err();
+        assert_eq!(err.name, "NOT_FOUND");
+        assert_eq!(err.to_string(), "NOT_FOUND: x");
+
+        let data = decode_envelope(r#"{"ok":true,"data":{"a":1}}"#).unwrap();
+        assert_eq!(data["a"], 1);
+    }
+}
@@ -1,3 +1,5 @@
+use std::time::Duration;
+
 use async_trait::async_trait;
 use serde_json::json;
 
@@ -51,12 +53,23 @@ impl Tool for WebSearchTool {
             Ok(q) => q,
             Err(msg) => return ToolResult::error(msg),
         };
-        let _timeout_secs = resolve_timeout_secs(&input, self.default_timeout_secs);
-
-        // Call servicor search function
-        servicor::search(&query);
-        // Since the Go function doesn't return a value, we'll return a success message
-        ToolResult::success("Search executed successfully. Results are printed to console.".into())
+        let timeout_secs = resolve_timeout_secs(&input, self.default_timeout_secs);
+
+        // servicor 的搜索会阻塞线程（可能启动浏览器），不能在 tokio 的工作线程上执行；
+        // 超时后搜索仍在后台线程中执行，直到 Go 侧各引擎的超时使其结束
+        let search_query = query.clone();
+        let task = tokio::task::spawn_blocking(move || servicor::search(&search_query));
+        match tokio::time::timeout(Duration::from_secs(timeout_secs), task).await {
+            Ok(Ok(Ok(results))) => {
+                if results.as_array().map_or(true, |items| items.is_empty()) {
+                    return ToolResult::success(format!("No results found for: {query}"));
+                }
+                ToolResult::success(results.to_string())
+            }
+            Ok(Ok(Err(e))) => ToolResult::error(format!("Search failed: {e}")),
+            Ok(Err(e)) => ToolResult::error(format!("Search task failed: {e}")),
+            Err(_) => ToolResult::error(format!("Search timed out after {timeout_secs}s")),
+        }
     }
 }
 